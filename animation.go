@@ -0,0 +1,59 @@
+package main
+
+import "time"
+
+// animationDelay is how long each move of a walk or macro push stays on screen, 0 to skip animating
+var animationDelay = 60 * time.Millisecond
+
+// animationQueue - the moves of a walk or macro push still to be shown, played one per tick of the
+// game loop and logged as a single undoable action once the last one is played
+type animationQueue struct {
+	steps  []Step
+	logged []byte
+	before int
+}
+
+var animation animationQueue
+
+// animate queues moves to play as one action
+func animate(level []string, steps []Step) {
+	animation = animationQueue{steps: steps, logged: flightRecorder.Bytes(), before: len(flightRecorder.Steps())}
+	if animationDelay <= 0 {
+		animation.skip(level)
+	}
+}
+
+func (a *animationQueue) busy() bool {
+	return len(a.steps) > 0
+}
+
+// step plays the next queued move
+func (a *animationQueue) step(level []string) {
+	if !a.busy() {
+		return
+	}
+	s := a.steps[0]
+	a.steps = a.steps[1:]
+	active := current
+	selectPlayer(s.Who)
+	movePlayer(level, s.Dir)
+	selectPlayer(active)
+	if !a.busy() {
+		a.finish()
+	}
+}
+
+// skip plays every queued move at once
+func (a *animationQueue) skip(level []string) {
+	for a.busy() {
+		a.step(level)
+	}
+}
+
+// finish groups the moves played so they are undone together
+func (a *animationQueue) finish() {
+	played := flightRecorder.Steps()[a.before:]
+	flightRecorder.LoadBytes(a.logged)
+	flightRecorder.PushGroup(played)
+	*a = animationQueue{}
+}
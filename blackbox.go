@@ -2,43 +2,181 @@ package main
 
 import "sync"
 
-type Pair struct {
-	P Player
-	B Boulder
+const (
+	pushFlag   = 0x10
+	groupStart = 0x40
+	groupEnd   = 0x41
+	pusherFlag = 0x80
+)
+
+// Step - one recorded move: which pusher moved, where, and whether it moved a boulder
+type Step struct {
+	Who    int
+	Dir    Move
+	Pushed bool
 }
 
-// PairStack the stack of pairs
-type PairStack struct {
-	pairs []Pair
+// MoveLog the moves of a level, one byte each: the Move in the low bits with pushFlag when a boulder moved.
+// A byte with pusherFlag set hands the following moves to the pusher in its low bits, and moves
+// between groupStart and groupEnd are undone together.
+// Positions are not stored: undo steps back from the move itself where the rules allow, and otherwise
+// works them out again by replaying the moves from the start of the level.
+type MoveLog struct {
+	bytes []byte
+	who   int
 	lock  sync.RWMutex
 }
 
-// New creates a new ItemStack
-func (s *PairStack) New() *PairStack {
-	s.pairs = []Pair{}
-	return s
+// Reset empties the log
+func (l *MoveLog) Reset() {
+	l.lock.Lock()
+	l.bytes = l.bytes[:0]
+	l.who = 0
+	l.lock.Unlock()
+}
+
+// IsEmpty returns true when no move is logged
+func (l *MoveLog) IsEmpty() bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return len(l.bytes) == 0
 }
 
-// IsEmpty returns true for empty stack
-func (s *PairStack) IsEmpty() bool {
-	return len(s.pairs) == 0
+// Push logs a move at the end
+func (l *MoveLog) Push(s Step) {
+	l.lock.Lock()
+	if s.Who != l.who {
+		l.bytes = append(l.bytes, pusherFlag|byte(s.Who))
+		l.who = s.Who
+	}
+	b := byte(s.Dir)
+	if s.Pushed {
+		b |= pushFlag
+	}
+	l.bytes = append(l.bytes, b)
+	l.lock.Unlock()
 }
 
-// Push adds a Pair to the top of the stack
-func (s *PairStack) Push(p Pair) {
-	s.lock.Lock()
-	s.pairs = append(s.pairs, p)
-	s.lock.Unlock()
+// Steps decodes the logged moves, oldest first
+func (l *MoveLog) Steps() []Step {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	steps := make([]Step, 0, len(l.bytes))
+	who := 0
+	for _, b := range l.bytes {
+		if b&pusherFlag != 0 {
+			who = int(b &^ pusherFlag)
+			continue
+		}
+		if b&groupStart != 0 {
+			continue
+		}
+		steps = append(steps, Step{who, Move(b &^ pushFlag), b&pushFlag != 0})
+	}
+	return steps
 }
 
-// Pop removes a Pair from the top of the stack
-func (s *PairStack) Pop() *Pair {
-	if s.IsEmpty() {
-		return nil
+// pop removes the last move, reporting false when there was none or a group starts there
+func (l *MoveLog) pop() (Step, bool) {
+	if len(l.bytes) == 0 || l.bytes[len(l.bytes)-1]&groupStart != 0 {
+		return Step{}, false
+	}
+	b := l.bytes[len(l.bytes)-1]
+	s := Step{l.who, Move(b &^ pushFlag), b&pushFlag != 0}
+	l.bytes = l.bytes[:len(l.bytes)-1]
+	handedOver := false
+	for len(l.bytes) > 0 && l.bytes[len(l.bytes)-1]&pusherFlag != 0 {
+		l.bytes = l.bytes[:len(l.bytes)-1]
+		handedOver = true
+	}
+	// the pusher only changes when a hand-over went with the move, so the log is not searched every time
+	if handedOver {
+		l.who = 0
+		for i := len(l.bytes) - 1; i >= 0; i-- {
+			if l.bytes[i]&pusherFlag != 0 {
+				l.who = int(l.bytes[i] &^ pusherFlag)
+				break
+			}
+		}
+	}
+	return s, true
+}
+
+// Last - the move PopGroup would remove first, without removing it, reporting false at the end of a group
+func (l *MoveLog) Last() (Step, bool) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if len(l.bytes) == 0 || l.bytes[len(l.bytes)-1]&groupStart != 0 {
+		return Step{}, false
+	}
+	b := l.bytes[len(l.bytes)-1]
+	return Step{l.who, Move(b &^ pushFlag), b&pushFlag != 0}, true
+}
+
+// Load replaces the log with the given moves
+func (l *MoveLog) Load(steps []Step) {
+	l.Reset()
+	for _, s := range steps {
+		l.Push(s)
+	}
+}
+
+// Bytes - a copy of the encoded log, for saving
+func (l *MoveLog) Bytes() []byte {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return append([]byte(nil), l.bytes...)
+}
+
+// LoadBytes replaces the log with one saved by Bytes
+func (l *MoveLog) LoadBytes(saved []byte) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.bytes = append(l.bytes[:0], saved...)
+	l.who = 0
+	for _, b := range l.bytes {
+		if b&pusherFlag != 0 {
+			l.who = int(b &^ pusherFlag)
+		}
+	}
+}
+
+// PushGroup logs moves that are undone together; a single move is logged as usual
+func (l *MoveLog) PushGroup(steps []Step) {
+	if len(steps) > 1 {
+		l.lock.Lock()
+		l.bytes = append(l.bytes, groupStart)
+		l.lock.Unlock()
+	}
+	for _, s := range steps {
+		l.Push(s)
+	}
+	if len(steps) > 1 {
+		l.lock.Lock()
+		l.bytes = append(l.bytes, groupEnd)
+		l.lock.Unlock()
+	}
+}
+
+// PopGroup removes the last move, or the whole group when the log ends with one, last move first
+func (l *MoveLog) PopGroup() ([]Step, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.bytes) == 0 || l.bytes[len(l.bytes)-1] != groupEnd {
+		s, ok := l.pop()
+		return []Step{s}, ok
+	}
+	l.bytes = l.bytes[:len(l.bytes)-1]
+	var steps []Step
+	for {
+		s, ok := l.pop()
+		if !ok {
+			break
+		}
+		steps = append(steps, s)
+	}
+	if len(l.bytes) > 0 && l.bytes[len(l.bytes)-1] == groupStart {
+		l.bytes = l.bytes[:len(l.bytes)-1]
 	}
-	s.lock.Lock()
-	pair := s.pairs[len(s.pairs)-1]
-	s.pairs = s.pairs[0 : len(s.pairs)-1]
-	s.lock.Unlock()
-	return &pair
+	return steps, len(steps) > 0
 }
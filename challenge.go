@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChallengeMode - a competitive way of playing a level
+type ChallengeMode int
+
+const (
+	casual ChallengeMode = iota
+	timeAttack
+	moveLimit
+	hardcore
+)
+
+var challengeModes = map[string]ChallengeMode{
+	"":         casual,
+	"time":     timeAttack,
+	"moves":    moveLimit,
+	"hardcore": hardcore,
+}
+
+var challengeNames = map[ChallengeMode]string{
+	casual:     "casual",
+	timeAttack: "time",
+	moveLimit:  "moves",
+	hardcore:   "hardcore",
+}
+
+const (
+	challengeFile    = "challenges.jsonl"
+	defaultTimeLimit = 120
+	challengeBase    = 1000
+)
+
+type challengeRun struct {
+	mode     ChallengeMode
+	limit    int
+	started  time.Time
+	allowed  int
+	attempt  int
+	message  string
+	levelIdx int
+}
+
+var challenge challengeRun
+
+// pars - the par of every level worked out so far, by level hash, so the solver runs once per level
+var pars = map[string]int{}
+
+// start begins an attempt at a level, working out its time or move allowance
+func (c *challengeRun) start(l Level, idx int) {
+	if c.levelIdx != idx || c.attempt == 0 {
+		c.attempt = 0
+		c.message = ""
+	}
+	c.levelIdx = idx
+	c.attempt++
+	c.started = time.Now()
+	c.allowed = c.limit
+	switch c.mode {
+	case timeAttack:
+		if c.allowed == 0 {
+			c.allowed = defaultTimeLimit
+		}
+	case moveLimit:
+		if c.allowed == 0 {
+			c.allowed = parMoves(l)
+		}
+	case hardcore:
+		// par only adds to the score here, so it is not worth running the solver for
+		if c.allowed == 0 {
+			c.allowed = len(l.Meta.Header["Solution"])
+		}
+	}
+}
+
+// playable reports why the attempt can't be played, if it can't: a move limit needs a par
+func (c *challengeRun) playable() (string, bool) {
+	if c.mode == moveLimit && c.allowed == 0 {
+		return fmt.Sprintf("maze %d has no known solution to set a move limit, give one with -limit", c.levelIdx), false
+	}
+	return "", true
+}
+
+// parMoves - the number of moves in the level's known solution, from its header or the solver, 0 when
+// there is none
+func parMoves(l Level) int {
+	if solution := l.Meta.Header["Solution"]; solution != "" {
+		return len(solution)
+	}
+	key := LevelHash(l, false)
+	if par, ok := pars[key]; ok {
+		return par
+	}
+	pars[key] = 0
+	if hexGrid || extendedRules || len(players) > 1 {
+		return 0
+	}
+	if solution, err := SolveLevel(l.Grid); err == nil {
+		pars[key] = len(solution)
+	}
+	return pars[key]
+}
+
+func (c *challengeRun) allowUndo() bool {
+	return c.mode != hardcore
+}
+
+func (c *challengeRun) elapsed() time.Duration {
+	return time.Since(c.started)
+}
+
+// failed reports why the attempt is lost, if it is
+func (c *challengeRun) failed() (string, bool) {
+	switch {
+	case c.mode == timeAttack && c.elapsed() > time.Duration(c.allowed)*time.Second:
+		return "time's up", true
+	case c.mode == moveLimit && c.allowed > 0 && len(moveHistory()) > c.allowed:
+		return "out of moves", true
+	}
+	return "", false
+}
+
+func (c *challengeRun) status() string {
+	switch c.mode {
+	case timeAttack:
+		left := time.Duration(c.allowed)*time.Second - c.elapsed()
+		return fmt.Sprintf("time attack %ds left  attempt %d  %s", int(left.Seconds()), c.attempt, c.message)
+	case moveLimit:
+		return fmt.Sprintf("move limit %d/%d  attempt %d  %s", len(moveHistory()), c.allowed, c.attempt, c.message)
+	case hardcore:
+		return fmt.Sprintf("hardcore, no undo  attempt %d  %s", c.attempt, c.message)
+	}
+	return ""
+}
+
+// score rewards finishing with seconds or moves to spare, hardcore counting moves under par
+func (c *challengeRun) score(moves int) int {
+	spare := c.allowed - moves
+	if c.mode == timeAttack {
+		spare = int((time.Duration(c.allowed)*time.Second - c.elapsed()).Seconds())
+	}
+	if spare < 0 || c.allowed == 0 {
+		spare = 0
+	}
+	return challengeBase + 10*spare
+}
+
+// finish stores the outcome of a challenge attempt apart from casual play
+func (c *challengeRun) finish(l Level, completed bool) error {
+	if c.mode == casual {
+		return nil
+	}
+	solution := moveHistory()
+	r := Result{
+		Pack:      currentPack,
+		Profile:   profile.Name,
+		Level:     l.Meta.Number,
+		Hash:      LevelHash(l, false),
+		Mode:      challengeNames[c.mode],
+		Completed: completed,
+		Moves:     len(solution),
+		Pushes:    countPushes(solution),
+		Seconds:   c.elapsed().Seconds(),
+		When:      time.Now(),
+	}
+	if completed {
+		r.Score = c.score(r.Moves)
+		r.Solution = solution
+	}
+	return appendJSON(challengeFile, r)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var commands = map[string]func(args []string) error{
+	"play":        runPlay,
+	"lint":        runLint,
+	"generate":    runGenerate,
+	"edit":        runEdit,
+	"render":      runRender,
+	"serve":       runServe,
+	"join":        runJoin,
+	"scores":      runScores,
+	"stats":       runStats,
+	"convert":     runConvert,
+	"levelstring": runLevelString,
+	"transform":   runTransform,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: sokobango [command] [args]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  play          play a pack, optionally in reverse mode (see play -h)")
+	fmt.Fprintln(os.Stderr, "  lint [pack]   check a level pack for broken or unsolvable mazes")
+	fmt.Fprintln(os.Stderr, "  generate      build random solvable levels (see generate -h)")
+	fmt.Fprintln(os.Stderr, "  edit [file]   edit a level pack in the terminal")
+	fmt.Fprintln(os.Stderr, "  serve         host a cooperative game over the network (see serve -h)")
+	fmt.Fprintln(os.Stderr, "  join addr     control one pusher in a game hosted with serve")
+	fmt.Fprintln(os.Stderr, "  render        draw a level as svg, png or an animated gif (see render -h)")
+	fmt.Fprintln(os.Stderr, "  scores        show the best results of each level (see scores -h)")
+	fmt.Fprintln(os.Stderr, "  stats         show the lifetime statistics of a profile (see stats -h)")
+	fmt.Fprintln(os.Stderr, "  convert pack  write a pack as native, xsb, slc or json (see convert -h)")
+	fmt.Fprintln(os.Stderr, "  levelstring   write mazes on one line each, for pasting (see levelstring -h)")
+	fmt.Fprintln(os.Stderr, "  transform     normalize, turn or mirror mazes and their solutions (see transform -h)")
+}
+
+// Dispatch - run the sub-command named by args, reporting whether one was found
+func Dispatch(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage()
+		return true
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown command:", args[0])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd(args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return true
+}
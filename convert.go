@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// packWriters - the formats a pack can be saved in, by name
+var packWriters = map[string]func(io.Writer, []Level) error{
+	"native": WriteLevels,
+	"xsb":    WriteXSB,
+	"slc":    WriteSLC,
+	"json":   WriteJSON,
+}
+
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	to := fs.String("to", "native", "format to write: native, xsb, slc or json")
+	out := fs.String("o", "", "output file (default standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// the pack may come before the flags
+	var in string
+	if fs.NArg() > 0 {
+		in = fs.Arg(0)
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+	}
+	if in == "" || fs.NArg() > 0 {
+		return errors.New("usage: sokobango convert <pack> [-to native|xsb|slc|json] [-o file]")
+	}
+	write, ok := packWriters[*to]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *to)
+	}
+	levels, err := LoadPack(in)
+	if err != nil {
+		return err
+	}
+	for _, loss := range conversionLosses(levels, *to) {
+		fmt.Fprintln(os.Stderr, "warning:", loss)
+	}
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	return write(w, levels)
+}
+
+// conversionLosses writes the levels in a format and reads them back, listing what did not survive
+func conversionLosses(levels []Level, format string) []string {
+	var buf bytes.Buffer
+	if err := packWriters[format](&buf, levels); err != nil {
+		return []string{err.Error()}
+	}
+	lines, err := readLines(&buf)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	back, err := ParsePack(lines)
+	if err != nil {
+		return []string{fmt.Sprintf("the %s output does not read back: %v", format, err)}
+	}
+	if len(back) != len(levels) {
+		return []string{fmt.Sprintf("%d levels written in %s read back as %d", len(levels), format, len(back))}
+	}
+	// the same loss on many levels is reported once
+	var losses []string
+	mazes := map[string][]int{}
+	lose := func(loss string, i int) {
+		if mazes[loss] == nil {
+			losses = append(losses, loss)
+		}
+		mazes[loss] = append(mazes[loss], i)
+	}
+	for i, l := range levels {
+		if strings.Join(comparableGrid(l), "\n") != strings.Join(comparableGrid(back[i]), "\n") {
+			lose(fmt.Sprintf("the grid changes in %s", format), i)
+		}
+		var lost []string
+		for key, value := range l.Meta.Header {
+			if key != "Maze" && back[i].Meta.Header[key] != value {
+				lost = append(lost, key)
+			}
+		}
+		if len(lost) > 0 {
+			sort.Strings(lost)
+			lose(fmt.Sprintf("%s has no room for %s", format, strings.Join(lost, ", ")), i)
+		}
+	}
+	for n, loss := range losses {
+		if len(mazes[loss]) == 1 {
+			losses[n] = fmt.Sprintf("maze %d: %s", mazes[loss][0], loss)
+		} else {
+			losses[n] = fmt.Sprintf("%d mazes from maze %d: %s", len(mazes[loss]), mazes[loss][0], loss)
+		}
+	}
+	return losses
+}
+
+// comparableGrid - the grid with the padding formats may add or drop taken away
+func comparableGrid(l Level) []string {
+	if isHexLevel(l) {
+		return trimLevel(l.Grid)
+	}
+	return normalizeGrid(l.Grid)
+}
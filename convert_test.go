@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var roundTripPacks = []string{"levels/maps.txt", "levels/hexoban.txt", "levels/extended.txt", "levels/multiban.txt"}
+
+// slcHeaders - the only header lines an SLC collection has room for
+var slcHeaders = map[string]bool{
+	"Title": true, "Copyright": true, "Collection": true, "Description": true,
+	"Email": true, "Url": true, "Collection copyright": true,
+}
+
+// lossyRoundTrips - the packs a format can't hold, where the losses must at least be reported:
+// XSB and SLC have no characters for the extended tiles, and SLC no room for the hex header
+var lossyRoundTrips = map[string]bool{
+	"xsb levels/extended.txt": true,
+	"slc levels/extended.txt": true,
+	"slc levels/hexoban.txt":  true,
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, pack := range roundTripPacks {
+		levels, err := LoadPack(pack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for format, write := range packWriters {
+			pack, format, write := pack, format, write
+			t.Run(format+" "+pack, func(t *testing.T) {
+				if lossyRoundTrips[format+" "+pack] {
+					if len(conversionLosses(levels, format)) == 0 {
+						t.Error("losses not reported")
+					}
+					return
+				}
+				var buf bytes.Buffer
+				if err := write(&buf, levels); err != nil {
+					t.Fatal(err)
+				}
+				lines, err := readLines(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				back, err := ParsePack(lines)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(back) != len(levels) {
+					t.Fatalf("%d levels read back as %d", len(levels), len(back))
+				}
+				for i, l := range levels {
+					want, got := strings.Join(comparableGrid(l), "\n"), strings.Join(comparableGrid(back[i]), "\n")
+					if got != want {
+						t.Errorf("maze %d: grid\n%s\nread back as\n%s", i, want, got)
+					}
+					for key, value := range l.Meta.Header {
+						if key == "Maze" || format == "slc" && !slcHeaders[key] {
+							continue
+						}
+						if back[i].Meta.Header[key] != value {
+							t.Errorf("maze %d: header %s %q read back as %q", i, key, value, back[i].Meta.Header[key])
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// latin1SLC - a collection saved by an older editor in ISO-8859-1, the \xe9 being an e with an acute accent
+const latin1SLC = "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+	"<SokobanLevels>\n" +
+	"<Title>Caf\xe9 levels</Title>\n" +
+	"<LevelCollection Copyright=\"Ren\xe9\">\n" +
+	"<Level Id=\"Premi\xe8re\" Width=\"5\" Height=\"3\">\n" +
+	"<L>#####</L>\n" +
+	"<L>#@$.#</L>\n" +
+	"<L>#####</L>\n" +
+	"</Level>\n" +
+	"</LevelCollection>\n" +
+	"</SokobanLevels>\n"
+
+func TestSLCLatin1(t *testing.T) {
+	levels, err := ParsePack(strings.Split(latin1SLC, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 1 {
+		t.Fatalf("%d levels read", len(levels))
+	}
+	want := map[string]string{"Collection": "Café levels", "Collection copyright": "René", "Title": "Première"}
+	for key, value := range want {
+		if got := levels[0].Meta.Header[key]; got != value {
+			t.Errorf("header %s %q read as %q", key, value, got)
+		}
+	}
+	if got := strings.Join(levels[0].Grid, "\n"); got != "XXXXX\nX@*.X\nXXXXX" {
+		t.Errorf("grid read as\n%s", got)
+	}
+}
+
+// TestXSBMazeTitles reads an XSB pack whose titles start with "Maze", which once passed for native headers
+func TestXSBMazeTitles(t *testing.T) {
+	lines := []string{"Maze 1", "#####", "#@$.#", "#####", "", "Maze 2", "#####", "#.$@#", "#####"}
+	if format := packFormat(lines); format != "xsb" {
+		t.Fatalf("read as %s", format)
+	}
+	levels, err := ParsePack(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 2 {
+		t.Fatalf("%d levels read", len(levels))
+	}
+	if title := levels[0].Meta.Header["Title"]; title != "Maze 1" {
+		t.Errorf("title %q read as %q", "Maze 1", title)
+	}
+}
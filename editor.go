@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danicat/simpleansi"
+)
+
+const (
+	maxEditWidth  = 60
+	maxEditHeight = 40
+)
+
+var editorTiles = map[string]byte{
+	"x": 'X',
+	"#": 'X',
+	" ": ' ',
+	".": '.',
+	"*": '*',
+	"$": '*',
+	"@": '@',
+}
+
+type editor struct {
+	file    string
+	format  string
+	levels  []Level
+	idx     int
+	x, y    int
+	dirty   bool
+	message string
+}
+
+func runEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	format := fs.String("format", "", "save format: native, xsb, slc or json (default from the file)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("usage: sokobango edit [-format native|xsb|slc|json] [file]")
+	}
+	e := &editor{file: "levels.txt", format: *format}
+	if fs.NArg() == 1 {
+		e.file = fs.Arg(0)
+	}
+	if _, err := os.Stat(e.file); err == nil {
+		lines, err := LoadLevelFile(e.file)
+		if err != nil {
+			return err
+		}
+		if e.levels, err = ParsePack(lines); err != nil {
+			return err
+		}
+		if e.format == "" && len(e.levels) > 0 {
+			e.format = packFormat(lines)
+		}
+	}
+	if e.format == "" {
+		e.format = "native"
+		switch strings.ToLower(filepath.Ext(e.file)) {
+		case ".xsb", ".sok":
+			e.format = "xsb"
+		case ".slc":
+			e.format = "slc"
+		case ".json":
+			e.format = "json"
+		}
+	}
+	if _, ok := packWriters[e.format]; !ok {
+		return fmt.Errorf("unknown format %q", e.format)
+	}
+	if len(e.levels) == 0 {
+		e.newLevel()
+	}
+
+	Initialise()
+	defer Cleanup()
+	e.run(startInput())
+	return nil
+}
+
+func (e *editor) run(input <-chan string) {
+	quitting := false
+	for {
+		e.draw()
+		evt := <-input
+		if evt != "ESC" && evt != "q" {
+			quitting = false
+		}
+		e.message = ""
+		switch evt {
+		case "ESC", "q":
+			if !e.dirty || quitting {
+				simpleansi.ClearScreen()
+				return
+			}
+			quitting = true
+			e.message = "unsaved changes, press again to quit"
+		case "UP":
+			e.moveCursor(-1, 0)
+		case "DOWN":
+			e.moveCursor(1, 0)
+		case "LEFT":
+			e.moveCursor(0, -1)
+		case "RIGHT":
+			e.moveCursor(0, 1)
+		case "f":
+			e.floodOutside()
+		case "p":
+			e.playTest(input)
+		case "s":
+			e.save()
+		case "n":
+			e.newLevel()
+		case ">":
+			e.selectLevel(e.idx + 1)
+		case "<":
+			e.selectLevel(e.idx - 1)
+		case "BACKSPACE":
+			e.place(' ')
+		default:
+			if tile, ok := editorTiles[evt]; ok {
+				e.place(tile)
+			}
+		}
+	}
+}
+
+func (e *editor) grid() []string {
+	return e.levels[e.idx].Grid
+}
+
+func (e *editor) newLevel() {
+	grid := []string{"XXXXXXXXXX"}
+	for i := 0; i < 5; i++ {
+		grid = append(grid, "X        X")
+	}
+	grid = append(grid, "XXXXXXXXXX")
+	e.levels = append(e.levels, Level{Meta: newLevelMeta(len(e.levels)), Grid: grid})
+	e.selectLevel(len(e.levels) - 1)
+	e.dirty = true
+}
+
+func (e *editor) selectLevel(idx int) {
+	if idx < 0 || idx >= len(e.levels) {
+		return
+	}
+	e.idx = idx
+	e.x, e.y = 0, 0
+	e.levels[idx].Grid = padGrid(e.levels[idx].Grid)
+}
+
+// moveCursor steps the cursor, growing the grid when it walks off the bottom or right edge
+func (e *editor) moveCursor(dx int, dy int) {
+	x, y := e.x+dx, e.y+dy
+	if x < 0 || y < 0 || x >= maxEditHeight || y >= maxEditWidth {
+		return
+	}
+	grid := e.grid()
+	width, _ := gridSize(grid)
+	for x >= len(grid) {
+		grid = append(grid, strings.Repeat(" ", width))
+	}
+	if y >= width {
+		for i := range grid {
+			grid[i] += strings.Repeat(" ", y-width+1)
+		}
+	}
+	e.levels[e.idx].Grid = padGrid(grid)
+	e.x, e.y = x, y
+}
+
+// place sets the tile under the cursor, combining goals with boxes and the player
+func (e *editor) place(tile byte) {
+	grid := e.grid()
+	current := grid[e.x][e.y]
+	onGoal := current == '.' || current == '&' || current == '+'
+	switch tile {
+	case '.':
+		switch current {
+		case '*', '&':
+			tile = '&'
+		case '@', '+':
+			tile = '+'
+		}
+	case '*':
+		if onGoal {
+			tile = '&'
+		}
+	case '@':
+		for x, line := range grid {
+			if declaredPlayers(e.levels[e.idx].Meta) > 1 {
+				break
+			}
+			grid[x] = strings.NewReplacer("@", " ", "+", ".").Replace(line)
+		}
+		if onGoal {
+			tile = '+'
+		}
+	}
+	grid[e.x] = setTile(grid[e.x], e.y, tile)
+	e.dirty = true
+}
+
+// floodOutside clears every cell that can be reached from the edge of the grid without crossing a wall
+func (e *editor) floodOutside() {
+	grid := e.grid()
+	seen := map[cell]bool{}
+	var queue []cell
+	for x, line := range grid {
+		for y := range line {
+			if (x == 0 || y == 0 || x == len(grid)-1 || y == len(line)-1) && line[y] != 'X' {
+				seen[cell{x, y}] = true
+				queue = append(queue, cell{x, y})
+			}
+		}
+	}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		grid[c.X] = setTile(grid[c.X], c.Y, ' ')
+		for _, d := range neighbours {
+			n := cell{c.X + d.X, c.Y + d.Y}
+			if seen[n] || n.X < 0 || n.X >= len(grid) || n.Y < 0 || n.Y >= len(grid[n.X]) || grid[n.X][n.Y] == 'X' {
+				continue
+			}
+			seen[n] = true
+			queue = append(queue, n)
+		}
+	}
+	e.dirty = true
+	e.message = fmt.Sprintf("cleared %d outside cells", len(seen))
+}
+
+// playTesting is set while the editor play-tests a level: test runs stay off the scores, sessions and
+// progress, and are neither saved nor resumed
+var playTesting bool
+
+func (e *editor) playTest(input <-chan string) {
+	if issues := LintLevel(e.idx, e.levels[e.idx]); len(issues) > 0 {
+		e.message = "fix the level before play-testing: " + issues[0].Message
+		return
+	}
+	level := Level{Meta: e.levels[e.idx].Meta, Grid: append([]string(nil), e.grid()...)}
+	currentPack = e.file
+	playTesting = true
+	defer func() { playTesting = false }()
+	if runGame([]Level{level}, 0, input) {
+		e.message = "play-test: solved"
+	} else {
+		e.message = "play-test: stopped"
+	}
+}
+
+func (e *editor) save() {
+	levels := make([]Level, len(e.levels))
+	for i, l := range e.levels {
+		l.Grid = trimLevel(l.Grid)
+		l.Meta.Width, l.Meta.Height = gridSize(l.Grid)
+		if _, ok := l.Meta.Header["Size X"]; ok {
+			l.Meta.Header["Size X"] = strconv.Itoa(l.Meta.Width)
+			l.Meta.Header["Size Y"] = strconv.Itoa(l.Meta.Height)
+		}
+		levels[i] = l
+	}
+	f, err := os.Create(e.file)
+	if err != nil {
+		e.message = err.Error()
+		return
+	}
+	defer f.Close()
+	if err = packWriters[e.format](f, levels); err != nil {
+		e.message = err.Error()
+		return
+	}
+	e.dirty = false
+	e.message = fmt.Sprintf("saved %d levels to %s (%s)", len(e.levels), e.file, e.format)
+}
+
+func (e *editor) draw() {
+	simpleansi.ClearScreen()
+	grid := e.grid()
+	for x, line := range grid {
+		for y, chr := range line {
+			glyph := string(chr)
+			if chr == 'X' {
+				glyph = " "
+			}
+			switch {
+			case x == e.x && y == e.y:
+				fmt.Print(simpleansi.WithBlueBackground(glyph))
+			case chr == 'X':
+				fmt.Print(simpleansi.WithBackground(glyph, simpleansi.GREEN))
+			default:
+				fmt.Print(glyph)
+			}
+		}
+		fmt.Println()
+	}
+	state := "saved"
+	if e.dirty {
+		state = "modified"
+	}
+	fmt.Printf("\n%s  maze %d/%d  (%d,%d)  %s\n", e.file, e.idx+1, len(e.levels), e.x, e.y, state)
+	fmt.Println("x wall  space floor  . goal  * box  @ player  f fill outside  p play  s save  n new  < > level  q quit")
+	issues := LintLevel(e.idx, e.levels[e.idx])
+	for i, issue := range issues {
+		if i == 3 {
+			fmt.Printf("... and %d more\n", len(issues)-i)
+			break
+		}
+		fmt.Println(issue.Message)
+	}
+	if len(issues) == 0 {
+		fmt.Println("level ok")
+	}
+	if e.message != "" {
+		fmt.Println(e.message)
+	}
+}
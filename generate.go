@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// roomTemplates - 3x3 building blocks the generator tiles into a room, applied in any rotation
+var roomTemplates = [][3]string{
+	{"   ", "   ", "   "},
+	{"X  ", "   ", "   "},
+	{"XX ", "   ", "   "},
+	{"XXX", "   ", "   "},
+	{"XXX", "X  ", "X  "},
+	{"X  ", "   ", "  X"},
+	{"   ", " X ", "   "},
+	{"X  ", "XX ", "   "},
+	{"XX ", "X  ", "   "},
+	{"X X", "   ", "   "},
+	{" X ", "   ", "   "},
+	{"   ", "XXX", "   "},
+}
+
+// GeneratorOptions - the knobs for building random levels
+type GeneratorOptions struct {
+	Width, Height int
+	Boxes         int
+	Difficulty    int
+	Attempts      int
+	Seed          int64
+}
+
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	opts := GeneratorOptions{}
+	fs.IntVar(&opts.Width, "width", 9, "interior width of the room")
+	fs.IntVar(&opts.Height, "height", 9, "interior height of the room")
+	fs.IntVar(&opts.Boxes, "boxes", 3, "number of boxes")
+	fs.IntVar(&opts.Difficulty, "difficulty", 3, "target difficulty from 1 to 10")
+	fs.IntVar(&opts.Attempts, "attempts", 40, "candidate rooms tried per level")
+	fs.Int64Var(&opts.Seed, "seed", 0, "random seed, 0 picks one from the clock")
+	count := fs.Int("count", 1, "number of levels to generate")
+	out := fs.String("o", "", "write the pack to a file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+
+	levels, err := GeneratePack(opts, *count)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return WriteLevels(w, levels)
+}
+
+// GeneratePack - build a pack of solvable levels, reproducible for a given seed
+func GeneratePack(opts GeneratorOptions, count int) ([]Level, error) {
+	if opts.Width < 3 || opts.Height < 3 {
+		return nil, errors.New("room must be at least 3x3")
+	}
+	if opts.Boxes < 1 {
+		return nil, errors.New("need at least one box")
+	}
+	if opts.Difficulty < 1 || opts.Difficulty > 10 {
+		return nil, errors.New("difficulty must be between 1 and 10")
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	var levels []Level
+	for i := 0; i < count; i++ {
+		grid, solution, err := generateLevel(rnd, opts)
+		if err != nil {
+			return nil, err
+		}
+		meta := newLevelMeta(i)
+		meta.Width, meta.Height = gridSize(grid)
+		meta.Header["Size X"] = strconv.Itoa(meta.Width)
+		meta.Header["Size Y"] = strconv.Itoa(meta.Height)
+		meta.Header["Seed"] = strconv.FormatInt(opts.Seed, 10)
+		meta.Header["Solution"] = solution
+		levels = append(levels, Level{Meta: meta, Grid: grid})
+	}
+	return levels, nil
+}
+
+func generateLevel(rnd *rand.Rand, opts GeneratorOptions) ([]string, string, error) {
+	target := opts.Difficulty * opts.Boxes * 3
+	var best []string
+	var bestSolution string
+	for attempt := 0; attempt < opts.Attempts; attempt++ {
+		room := buildRoom(rnd, opts.Width, opts.Height)
+		if room == nil {
+			continue
+		}
+		grid := placeBoxes(rnd, room, opts.Boxes, opts.Difficulty*opts.Boxes*15)
+		if grid == nil {
+			continue
+		}
+		p, err := newPuzzle(grid)
+		if err != nil || p.solved(p.boxes) {
+			continue
+		}
+		solution, err := p.Solve(defaultSolverLimit)
+		if err != nil {
+			continue
+		}
+		if best == nil || abs(countPushes(solution)-target) < abs(countPushes(bestSolution)-target) {
+			best, bestSolution = grid, solution
+		}
+	}
+	if best == nil {
+		return nil, "", errors.New("could not generate a solvable level, try a bigger room or fewer boxes")
+	}
+	return best, bestSolution, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// buildRoom tiles random templates into a walled room and keeps its largest open area
+func buildRoom(rnd *rand.Rand, width int, height int) [][]byte {
+	bw, bh := (width+2)/3, (height+2)/3
+	room := make([][]byte, bh*3+2)
+	for x := range room {
+		room[x] = make([]byte, bw*3+2)
+		for y := range room[x] {
+			room[x][y] = 'X'
+		}
+	}
+	for bx := 0; bx < bh; bx++ {
+		for by := 0; by < bw; by++ {
+			t := roomTemplates[rnd.Intn(len(roomTemplates))]
+			turns, mirror := rnd.Intn(4), rnd.Intn(2) == 1
+			for i := 0; i < 3; i++ {
+				for j := 0; j < 3; j++ {
+					x, y := i, j
+					if mirror {
+						y = 2 - y
+					}
+					for r := 0; r < turns; r++ {
+						x, y = y, 2-x
+					}
+					room[bx*3+1+i][by*3+1+j] = t[x][y]
+				}
+			}
+		}
+	}
+
+	var largest map[cell]bool
+	for x := range room {
+		for y := range room[x] {
+			c := cell{x, y}
+			if room[x][y] != ' ' || largest[c] {
+				continue
+			}
+			area := roomArea(room, c)
+			if len(area) > len(largest) {
+				largest = area
+			}
+		}
+	}
+	if len(largest) < 8 {
+		return nil
+	}
+	for x := range room {
+		for y := range room[x] {
+			if !largest[cell{x, y}] {
+				room[x][y] = 'X'
+			}
+		}
+	}
+	return room
+}
+
+func roomArea(room [][]byte, from cell) map[cell]bool {
+	area := map[cell]bool{from: true}
+	queue := []cell{from}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, d := range neighbours {
+			n := cell{c.X + d.X, c.Y + d.Y}
+			if area[n] || room[n.X][n.Y] != ' ' {
+				continue
+			}
+			area[n] = true
+			queue = append(queue, n)
+		}
+	}
+	return area
+}
+
+// placeBoxes puts boxes on goals and the player at random, then plays backwards pulling boxes off the goals
+func placeBoxes(rnd *rand.Rand, room [][]byte, boxes int, steps int) []string {
+	var floor []cell
+	for x := range room {
+		for y := range room[x] {
+			if room[x][y] == ' ' {
+				floor = append(floor, cell{x, y})
+			}
+		}
+	}
+	if len(floor) < boxes*2+1 {
+		return nil
+	}
+	rnd.Shuffle(len(floor), func(i, j int) { floor[i], floor[j] = floor[j], floor[i] })
+	goals := floor[:boxes]
+	player := floor[boxes]
+	isBox := map[cell]bool{}
+	for _, g := range goals {
+		isBox[g] = true
+	}
+
+	for step := 0; step < steps; step++ {
+		d := neighbours[rnd.Intn(len(neighbours))]
+		next := cell{player.X + d.X, player.Y + d.Y}
+		if room[next.X][next.Y] == 'X' || isBox[next] {
+			continue
+		}
+		behind := cell{player.X - d.X, player.Y - d.Y}
+		if isBox[behind] && rnd.Intn(3) > 0 {
+			delete(isBox, behind)
+			isBox[player] = true
+		}
+		player = next
+	}
+
+	grid := make([]string, len(room))
+	for x := range room {
+		grid[x] = string(room[x])
+	}
+	for _, g := range goals {
+		grid[g.X] = setTile(grid[g.X], g.Y, '.')
+	}
+	for x := range room {
+		for y := range room[x] {
+			c := cell{x, y}
+			if !isBox[c] {
+				continue
+			}
+			if grid[x][y] == '.' {
+				grid[x] = setTile(grid[x], y, '&')
+			} else {
+				grid[x] = setTile(grid[x], y, '*')
+			}
+		}
+	}
+	if grid[player.X][player.Y] == '.' {
+		grid[player.X] = setTile(grid[player.X], player.Y, '+')
+	} else {
+		grid[player.X] = setTile(grid[player.X], player.Y, '@')
+	}
+	return cropGrid(grid)
+}
+
+// cropGrid cuts a fully walled grid down to its open area, clearing walls that touch no floor
+func cropGrid(grid []string) []string {
+	open := func(x int, y int) bool {
+		return x >= 0 && x < len(grid) && y >= 0 && y < len(grid[x]) && grid[x][y] != 'X'
+	}
+	top, left, bottom, right := len(grid), len(grid[0]), -1, -1
+	for x, line := range grid {
+		for y := range line {
+			if !open(x, y) {
+				continue
+			}
+			if x < top {
+				top = x
+			}
+			if x > bottom {
+				bottom = x
+			}
+			if y < left {
+				left = y
+			}
+			if y > right {
+				right = y
+			}
+		}
+	}
+	var cropped []string
+	for x := top - 1; x <= bottom+1; x++ {
+		line := make([]byte, 0, right-left+3)
+		for y := left - 1; y <= right+1; y++ {
+			tile := byte(' ')
+			if open(x, y) {
+				tile = grid[x][y]
+			} else if open(x-1, y-1) || open(x-1, y) || open(x-1, y+1) || open(x, y-1) ||
+				open(x, y+1) || open(x+1, y-1) || open(x+1, y) || open(x+1, y+1) {
+				tile = 'X'
+			}
+			line = append(line, tile)
+		}
+		cropped = append(cropped, string(line))
+	}
+	return cropped
+}
+
+func setTile(line string, y int, tile byte) string {
+	b := []byte(line)
+	b[y] = tile
+	return string(b)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// idSpace is the uuid namespace deterministic boulder and target IDs are made in
+var idSpace = uuid.MustParse("6f1d2a3c-5b4e-4c8d-9a7f-0e2b1c3d4e5f")
+
+// pieceID - a stable ID for the piece of a given kind starting on a cell, so histories are reproducible
+func pieceID(kind string, x int, y int) uuid.UUID {
+	return uuid.NewSHA1(idSpace, []byte(fmt.Sprintf("%s %d,%d", kind, x, y)))
+}
+
+// LevelHash - a short content hash identifying a level whatever pack or position it comes from.
+// With symmetric set, rotated and mirrored copies of a square level hash the same.
+func LevelHash(l Level, symmetric bool) string {
+	grid := normalizeGrid(l.Grid)
+	if isHexLevel(l) {
+		// dropping a leading row would swap which rows are shifted
+		grid = trimLevel(l.Grid)
+	}
+	best := strings.Join(grid, "\n")
+	if symmetric && !isHexLevel(l) {
+		// Grid normalizes the raw grid itself, and turns the one-way floor along with the walls
+		for _, name := range transformNames()[1:] {
+			if s := strings.Join(transforms[name].Grid(l.Grid), "\n"); s < best {
+				best = s
+			}
+		}
+	}
+	rules := "grid:" + l.Meta.Header["Grid"] + " rules:" + l.Meta.Header["Rules"] + "\n"
+	sum := sha256.Sum256([]byte(rules + best))
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalizeGrid drops blank rows and columns around a level and pads its lines to one width
+func normalizeGrid(level []string) []string {
+	level = trimLevel(level)
+	for len(level) > 0 && strings.TrimSpace(level[0]) == "" {
+		level = level[1:]
+	}
+	indent := -1
+	for _, line := range level {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " ")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	width := 0
+	grid := make([]string, len(level))
+	for x, line := range level {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		grid[x] = strings.TrimRight(line, " ")
+		if len(grid[x]) > width {
+			width = len(grid[x])
+		}
+	}
+	for x := range grid {
+		grid[x] += strings.Repeat(" ", width-len(grid[x]))
+	}
+	return grid
+}
+
+// rotateGrid turns a rectangular grid a quarter turn clockwise
+func rotateGrid(grid []string) []string {
+	width, _ := gridSize(grid)
+	rotated := make([]string, width)
+	for y := 0; y < width; y++ {
+		row := make([]byte, len(grid))
+		for x := range grid {
+			row[len(grid)-1-x] = grid[x][y]
+		}
+		rotated[y] = string(row)
+	}
+	return rotated
+}
+
+// mirrorGrid flips a rectangular grid left to right
+func mirrorGrid(grid []string) []string {
+	mirrored := make([]string, len(grid))
+	for x, line := range grid {
+		row := []byte(line)
+		for i, j := 0, len(row)-1; i < j; i, j = i+1, j-1 {
+			row[i], row[j] = row[j], row[i]
+		}
+		mirrored[x] = string(row)
+	}
+	return mirrored
+}
@@ -0,0 +1,95 @@
+package main
+
+import "strings"
+
+// hexGrid is set while the current level is a hex level, laid out in rows where every odd row is
+// shifted half a cell to the right
+var hexGrid bool
+
+var hexKeys = map[string]Move{
+	"LEFT":  left,
+	"RIGHT": right,
+	"a":     left,
+	"d":     right,
+	"q":     northWest,
+	"e":     northEast,
+	"z":     southWest,
+	"c":     southEast,
+}
+
+func moveNorthWest(level []string, x int, y int) (int, int) {
+	return x - 1, y - 1 + x%2
+}
+
+func moveNorthEast(level []string, x int, y int) (int, int) {
+	return x - 1, y + x%2
+}
+
+func moveSouthWest(level []string, x int, y int) (int, int) {
+	return x + 1, y - 1 + x%2
+}
+
+func moveSouthEast(level []string, x int, y int) (int, int) {
+	return x + 1, y + x%2
+}
+
+// adjacentCells lists the neighbours of a cell on the current grid
+func adjacentCells(c cell) []cell {
+	if !hexGrid {
+		return []cell{{c.X - 1, c.Y}, {c.X + 1, c.Y}, {c.X, c.Y - 1}, {c.X, c.Y + 1}}
+	}
+	var cells []cell
+	for _, dir := range []Move{left, right, northWest, northEast, southWest, southEast} {
+		x, y := moves[dir](nil, c.X, c.Y)
+		cells = append(cells, cell{x, y})
+	}
+	return cells
+}
+
+func isHexLevel(l Level) bool {
+	return l.Meta.Header["Grid"] == "hex"
+}
+
+// compactHexGrid reads a hex level drawn with a space between cells and odd rows indented by one
+func compactHexGrid(drawn []string) []string {
+	grid := make([]string, len(drawn))
+	for x, line := range drawn {
+		var row []byte
+		for i := x % 2; i < len(line); i += 2 {
+			row = append(row, line[i])
+		}
+		grid[x] = string(row)
+	}
+	return padGrid(grid)
+}
+
+// expandHexGrid draws a hex level back with a space between cells and odd rows indented by one
+func expandHexGrid(grid []string) []string {
+	drawn := make([]string, len(grid))
+	for x, line := range grid {
+		row := strings.Repeat(" ", x%2)
+		for y := range line {
+			if y > 0 {
+				row += " "
+			}
+			row += string(line[y])
+		}
+		drawn[x] = strings.TrimRight(row, " ")
+	}
+	return drawn
+}
+
+// screenColumn is the terminal column of a cell, spreading hex cells out so odd rows sit between even ones
+func screenColumn(x int, y int) int {
+	if hexGrid {
+		return 2*y + x%2
+	}
+	return y
+}
+
+func moveKeys() map[string]Move {
+	if hexGrid {
+		return hexKeys
+	}
+	return keys
+}
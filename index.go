@@ -0,0 +1,69 @@
+package main
+
+// pieceIndex - the boulders and targets laid out by cell, so finding a boulder, moving one and checking
+// whether the level is completed never scan the piece lists
+type pieceIndex struct {
+	width    int
+	height   int
+	boulders []*Boulder
+	targets  []bool
+	placed   int
+}
+
+var pieces pieceIndex
+
+// indexPieces rebuilds the index from the boulders and targets after they are set up
+func indexPieces(level []string) {
+	width, height := gridSize(level)
+	pieces = pieceIndex{
+		width:    width,
+		height:   height,
+		boulders: make([]*Boulder, width*height),
+		targets:  make([]bool, width*height),
+	}
+	for _, t := range targets {
+		if c := pieces.cell(t.X, t.Y); c >= 0 {
+			pieces.targets[c] = true
+		}
+	}
+	for _, b := range boulders {
+		pieces.put(b)
+	}
+}
+
+// cell - the slot of a position, or -1 outside the level
+func (ix *pieceIndex) cell(x int, y int) int {
+	if x < 0 || y < 0 || x >= ix.height || y >= ix.width {
+		return -1
+	}
+	return x*ix.width + y
+}
+
+func (ix *pieceIndex) put(b *Boulder) {
+	c := ix.cell(b.X, b.Y)
+	if c < 0 {
+		return
+	}
+	ix.boulders[c] = b
+	if ix.targets[c] {
+		ix.placed++
+	}
+}
+
+func (ix *pieceIndex) take(b *Boulder) {
+	c := ix.cell(b.X, b.Y)
+	if c < 0 || ix.boulders[c] != b {
+		return
+	}
+	ix.boulders[c] = nil
+	if ix.targets[c] {
+		ix.placed--
+	}
+}
+
+// moveBoulder is the one way boulders change cell, keeping the index up to date
+func moveBoulder(b *Boulder, x int, y int) {
+	pieces.take(b)
+	b.X, b.Y = x, y
+	pieces.put(b)
+}
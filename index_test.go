@@ -0,0 +1,121 @@
+package main
+
+import "testing"
+
+// benchLevel sets up the bundled maze with the most boxes, where scanning the piece lists cost the most
+func benchLevel(b *testing.B) []string {
+	levels, err := LoadPack("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	most := 0
+	for i, l := range levels {
+		if len(initBoulder(l.Grid)) > len(initBoulder(levels[most].Grid)) {
+			most = i
+		}
+	}
+	return initLevel(levels, most)
+}
+
+// scanBoulderAt finds a boulder the way it was done before the index, for comparison
+func scanBoulderAt(x int, y int) *Boulder {
+	for _, cand := range boulders {
+		if cand.X == x && cand.Y == y {
+			return cand
+		}
+	}
+	return nil
+}
+
+func BenchmarkBoxLookup(b *testing.B) {
+	level := benchLevel(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for x, line := range level {
+			for y := range line {
+				getBoulderAtPosition(x, y)
+			}
+		}
+	}
+}
+
+func BenchmarkBoxLookupScan(b *testing.B) {
+	level := benchLevel(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for x, line := range level {
+			for y := range line {
+				scanBoulderAt(x, y)
+			}
+		}
+	}
+}
+
+// benchMoveUndo times a move and its undo with history moves logged before it, walking to and fro
+// in a direction that pushes nothing so the position stays the same
+func benchMoveUndo(b *testing.B, history int, takeBack func(level []string)) {
+	level := benchLevel(b)
+	dir := none
+	for _, d := range []Move{up, down, left, right} {
+		movePlayer(level, d)
+		if s, ok := flightRecorder.Last(); ok {
+			undo(level)
+			if !s.Pushed {
+				dir = d
+				break
+			}
+		}
+	}
+	if dir == none {
+		b.Skip("the pusher can't step anywhere without pushing")
+	}
+	for i := 0; i < history; i++ {
+		movePlayer(level, dir)
+		movePlayer(level, opposite[dir])
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		movePlayer(level, dir)
+		takeBack(level)
+	}
+}
+
+func BenchmarkMoveUndo(b *testing.B) {
+	benchMoveUndo(b, 0, undo)
+}
+
+func BenchmarkMoveUndoLongHistory(b *testing.B) {
+	benchMoveUndo(b, 5000, undo)
+}
+
+// BenchmarkMoveUndoReplayLongHistory undoes by replaying the log, as levels with extended tiles do
+func BenchmarkMoveUndoReplayLongHistory(b *testing.B) {
+	benchMoveUndo(b, 5000, func(level []string) {
+		flightRecorder.PopGroup()
+		replayLog(level)
+	})
+}
+
+func BenchmarkLevelCompleted(b *testing.B) {
+	benchLevel(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		isLevelCompleted()
+	}
+}
+
+func BenchmarkLevelCompletedScan(b *testing.B) {
+	benchLevel(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		placed := 0
+		for _, bl := range boulders {
+			for _, t := range targets {
+				if bl.X == t.X && bl.Y == t.Y {
+					placed++
+				}
+			}
+		}
+		_ = placed == len(boulders)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxLevelSide - the most rows or columns a level string may expand to, so a stray count can't
+// make it allocate gigabytes
+const maxLevelSide = 256
+
+// ParseLevelString - reading a maze written on one line in the run-length encoded notation:
+// XSB tiles with '-' or '_' for floor, a count before a tile or a bracketed group repeats it,
+// and '|' ends a row, as in "7#|#.@-#-#|#$*-$-#|#3-$-#|#-..--#|#--*--#|7#"
+func ParseLevelString(s string) (Level, error) {
+	expanded, closed, rest, err := expandRuns(strings.TrimSpace(s))
+	if err != nil {
+		return Level{}, err
+	}
+	if closed || rest != "" {
+		return Level{}, errors.New("level string has an unmatched )")
+	}
+	var grid []string
+	for _, line := range strings.Split(expanded, "|") {
+		if char := unknownTile(line, fromXSB); char != 0 {
+			return Level{}, fmt.Errorf("level string has an unknown tile %q", char)
+		}
+		grid = append(grid, mapTiles(line, fromXSB))
+	}
+	grid = trimLevel(grid)
+	if len(grid) == 0 {
+		return Level{}, errors.New("level string is empty")
+	}
+	return Level{Meta: newLevelMeta(0), Grid: padGrid(grid)}, nil
+}
+
+// expandRuns expands the counts up to the end of s or the bracket closing the group it is in,
+// reporting whether it stopped at a bracket and what follows it
+func expandRuns(s string) (string, bool, string, error) {
+	var b strings.Builder
+	for s != "" {
+		digits := 0
+		for digits < len(s) && s[digits] >= '0' && s[digits] <= '9' {
+			digits++
+		}
+		count := 1
+		if digits > 0 {
+			var err error
+			if count, err = strconv.Atoi(s[:digits]); err != nil || count > maxLevelSide {
+				return "", false, "", fmt.Errorf("level string repeats a tile %s times, more than the %d a level may be wide or high", s[:digits], maxLevelSide)
+			}
+			s = s[digits:]
+		}
+		if s == "" || digits > 0 && s[0] == ')' {
+			return "", false, "", errors.New("level string has a count with nothing to repeat")
+		}
+		var part string
+		switch s[0] {
+		case ')':
+			return b.String(), true, s[1:], nil
+		case '(':
+			inner, closed, rest, err := expandRuns(s[1:])
+			if err != nil {
+				return "", false, "", err
+			}
+			if !closed {
+				return "", false, "", errors.New("level string has an unmatched (")
+			}
+			part, s = inner, rest
+		default:
+			part, s = s[:1], s[1:]
+		}
+		if b.Len()+len(part)*count > maxLevelSide*(maxLevelSide+1) {
+			return "", false, "", fmt.Errorf("level string is larger than %d by %d tiles", maxLevelSide, maxLevelSide)
+		}
+		b.WriteString(strings.Repeat(part, count))
+	}
+	return b.String(), false, "", nil
+}
+
+// LevelString - a maze on one line in the run-length encoded notation ParseLevelString reads
+func LevelString(l Level) (string, error) {
+	if isHexLevel(l) {
+		return "", errors.New("hex levels have no level string")
+	}
+	var rows []string
+	for _, line := range normalizeGrid(l.Grid) {
+		if char := unknownTile(line, toXSB); char != 0 {
+			return "", fmt.Errorf("level strings have no tile for %q", char)
+		}
+		row := strings.ReplaceAll(mapTiles(line, toXSB), " ", "-")
+		rows = append(rows, encodeRuns(strings.TrimRight(row, "-")))
+	}
+	return strings.Join(rows, "|"), nil
+}
+
+// encodeRuns writes every run of two or more of the same tile as its length and the tile
+func encodeRuns(row string) string {
+	var b strings.Builder
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if j-i > 1 {
+			b.WriteString(strconv.Itoa(j - i))
+		}
+		b.WriteByte(row[i])
+		i = j
+	}
+	return b.String()
+}
+
+func runLevelString(args []string) error {
+	fs := flag.NewFlagSet("levelstring", flag.ContinueOnError)
+	pack := fs.String("pack", "", "level pack (default the bundled pack)")
+	level := fs.Int("level", -1, "maze to write (default every maze, one per line)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	levels, err := LoadPack(*pack)
+	if err != nil {
+		return err
+	}
+	if *level >= len(levels) {
+		return fmt.Errorf("level %d out of range, the pack has %d levels", *level, len(levels))
+	}
+	if *level >= 0 {
+		levels = levels[*level : *level+1]
+	}
+	for _, l := range levels {
+		s, err := LevelString(l)
+		if err != nil {
+			return fmt.Errorf("maze %d: %v", l.Meta.Number, err)
+		}
+		fmt.Println(s)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LintIssue - a single problem found in a level
+type LintIssue struct {
+	Level   int
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("maze %d: %s", i.Level, i.Message)
+}
+
+type cell struct {
+	X, Y int
+}
+
+var neighbours = []cell{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+func runLint(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: sokobango lint [pack]")
+	}
+	var file string
+	if len(args) == 1 {
+		file = args[0]
+	}
+	levels, err := LoadPack(file)
+	if err != nil {
+		return err
+	}
+	issues := LintLevels(levels)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d issue(s) found", len(issues))
+	}
+	return nil
+}
+
+// LintLevels - check every level of a pack and its header
+func LintLevels(levels []Level) []LintIssue {
+	var issues []LintIssue
+	seen := map[string]int{}
+	for idx, level := range levels {
+		hexGrid = isHexLevel(level)
+		extendedRules = isExtendedLevel(level)
+		issues = append(issues, LintLevel(idx, level)...)
+		issues = append(issues, lintHeader(idx, levels)...)
+		hash := LevelHash(level, true)
+		if first, ok := seen[hash]; ok {
+			issues = append(issues, LintIssue{idx, fmt.Sprintf("same level as maze %d, up to rotation and mirroring", first)})
+		} else {
+			seen[hash] = idx
+		}
+	}
+	return issues
+}
+
+// LintLevel - check the grid of a single level
+func LintLevel(idx int, l Level) []LintIssue {
+	var issues []LintIssue
+	level := l.Grid
+	report := func(format string, a ...interface{}) {
+		issues = append(issues, LintIssue{idx, fmt.Sprintf(format, a...)})
+	}
+	level = trimLevel(level)
+	if len(level) == 0 {
+		report("empty level")
+		return issues
+	}
+
+	width, _ := gridSize(level)
+	var players, boxes, goals []cell
+	for x, line := range level {
+		if len(line) != width {
+			report("ragged line %d: %d columns, expected %d", x, len(line), width)
+		}
+		for y, char := range line {
+			switch char {
+			case '@':
+				players = append(players, cell{x, y})
+			case '+':
+				players = append(players, cell{x, y})
+				goals = append(goals, cell{x, y})
+			case '*':
+				boxes = append(boxes, cell{x, y})
+			case '.':
+				goals = append(goals, cell{x, y})
+			case '&':
+				boxes = append(boxes, cell{x, y})
+				goals = append(goals, cell{x, y})
+			case 'X', ' ':
+			default:
+				if isExtendedLevel(l) && tileBehaviors[byte(char)] != nil {
+					continue
+				}
+				report("unknown tile %q at (%d,%d)", char, x, y)
+			}
+		}
+	}
+
+	pushers := declaredPlayers(l.Meta)
+	switch {
+	case len(players) == 0:
+		report("no player")
+	case len(players) != pushers && pushers == 1:
+		report("%d players", len(players))
+	case len(players) != pushers:
+		report("%d players but the header declares %d", len(players), pushers)
+	}
+	if len(boxes) != len(goals) {
+		report("%d boxes but %d goals", len(boxes), len(goals))
+	}
+	if len(boxes) == 0 {
+		report("no boxes")
+	}
+
+	if len(players) > 0 {
+		reach := map[cell]bool{}
+		for _, p := range players {
+			area, enclosed := floodFill(level, p)
+			if !enclosed {
+				report("player at (%d,%d) is not enclosed by walls", p.X, p.Y)
+			}
+			for c := range area {
+				reach[c] = true
+			}
+		}
+		for _, g := range goals {
+			if !reach[g] {
+				report("goal at (%d,%d) is unreachable", g.X, g.Y)
+			}
+		}
+		for _, b := range boxes {
+			if !reach[b] {
+				report("box at (%d,%d) is unreachable", b.X, b.Y)
+			}
+		}
+	}
+
+	for _, b := range boxes {
+		if tileAt(level, b.X, b.Y) == '&' {
+			continue
+		}
+		if !hexGrid && isCorner(level, b.X, b.Y) {
+			report("box at (%d,%d) is stuck in a corner", b.X, b.Y)
+		}
+	}
+	return issues
+}
+
+func lintHeader(idx int, levels []Level) []LintIssue {
+	var issues []LintIssue
+	report := func(format string, a ...interface{}) {
+		issues = append(issues, LintIssue{idx, fmt.Sprintf(format, a...)})
+	}
+	meta := levels[idx].Meta
+	width, height := gridSize(levels[idx].Grid)
+	declared := func(key string) bool {
+		_, ok := meta.Header[key]
+		return ok
+	}
+	if declared("Maze") && meta.Number != idx {
+		report("header says maze %q", meta.Header["Maze"])
+	}
+	if declared("Size X") && meta.Width != width {
+		report("header Size X %q but grid is %d wide", meta.Header["Size X"], width)
+	}
+	if declared("Size Y") && meta.Height != height {
+		report("header Size Y %q but grid is %d high", meta.Header["Size Y"], height)
+	}
+	if declared("File offset") || declared("End") || declared("Length") {
+		if meta.Offset < 0 || meta.End < 0 || meta.Length < 0 {
+			report("unreadable header offsets")
+		} else if meta.End-meta.Offset+1 != meta.Length {
+			report("header Length %d disagrees with offset %X and End %X", meta.Length, meta.Offset, meta.End)
+		}
+	}
+	if idx > 0 && declared("File offset") && meta.Header["File offset"] == levels[idx-1].Meta.Header["File offset"] {
+		report("header looks copied from maze %d", idx-1)
+	}
+	return issues
+}
+
+// declaredPlayers - the number of pushers a level's "Players" header asks for, one by default
+func declaredPlayers(meta LevelMeta) int {
+	if n, err := strconv.Atoi(meta.Header["Players"]); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+func gridSize(level []string) (width int, height int) {
+	for _, line := range level {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	return width, len(level)
+}
+
+func trimLevel(level []string) []string {
+	for len(level) > 0 && strings.TrimSpace(level[len(level)-1]) == "" {
+		level = level[:len(level)-1]
+	}
+	return level
+}
+
+func tileAt(level []string, x int, y int) byte {
+	if x < 0 || x >= len(level) || y < 0 || y >= len(level[x]) {
+		return ' '
+	}
+	return level[x][y]
+}
+
+func isWallTile(level []string, x int, y int) bool {
+	return tileAt(level, x, y) == 'X'
+}
+
+func isCorner(level []string, x int, y int) bool {
+	vertical := isWallTile(level, x-1, y) || isWallTile(level, x+1, y)
+	horizontal := isWallTile(level, x, y-1) || isWallTile(level, x, y+1)
+	return vertical && horizontal
+}
+
+func floodFill(level []string, from cell) (map[cell]bool, bool) {
+	reach := map[cell]bool{from: true}
+	enclosed := true
+	queue := []cell{from}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, n := range append(adjacentCells(c), teleportExits(level, c)...) {
+			if n.X < 0 || n.X >= len(level) || n.Y < 0 || n.Y >= len(level[n.X]) {
+				enclosed = false
+				continue
+			}
+			if reach[n] || isWallTile(level, n.X, n.Y) {
+				continue
+			}
+			reach[n] = true
+			queue = append(queue, n)
+		}
+	}
+	return reach, enclosed
+}
@@ -2,47 +2,218 @@ package main
 
 import (
 	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/markbates/pkger"
 )
 
+const bundledPack = "/levels/maps.txt"
+
+// mazeHeader - the "Maze: N" line that starts every level of a native pack, unlike an XSB title
+// such as "Maze 1"
+var mazeHeader = regexp.MustCompile(`^Maze:\s*\d+\s*$`)
+
 //ParseLevel - processing a set of maps and splitting into levels
 func ParseLevel(rawLevels []string) [][]string {
 	var maps [][]string
-	var mapa []string
-	var lidx int
+	for _, l := range ParseLevels(rawLevels) {
+		maps = append(maps, l.Grid)
+	}
+	return maps
+}
+
+// ParseLevels - splitting a set of maps into levels along with their header metadata
+func ParseLevels(rawLevels []string) []Level {
+	var levels []Level
+	var current *Level
+	inGrid := false
 	for _, line := range rawLevels {
-		if strings.Contains(line, "Maze") {
-			if len(mapa) > 0 {
-				mapa = mapa[:len(mapa)]
-				maps = append(maps, mapa)
-				mapa = []string{}
+		if mazeHeader.MatchString(line) {
+			levels = append(levels, Level{Meta: LevelMeta{Header: map[string]string{}}})
+			current = &levels[len(levels)-1]
+			inGrid = false
+		}
+		if current == nil {
+			continue
+		}
+		if !inGrid {
+			if kv := strings.SplitN(line, ":", 2); len(kv) == 2 {
+				current.Meta.Header[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+				continue
 			}
-			lidx = 0
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			inGrid = true
 		}
-		if lidx > 6 {
-			mapa = append(mapa, line)
+		current.Grid = append(current.Grid, line)
+	}
+	for i := range levels {
+		levels[i].Grid = trimLevel(levels[i].Grid)
+		levels[i].Meta.parseHeader()
+		if isHexLevel(levels[i]) {
+			levels[i].Grid = compactHexGrid(levels[i].Grid)
 		}
-		lidx++
 	}
-	return maps
+	return levels
+}
+
+func (m *LevelMeta) parseHeader() {
+	m.Number = headerInt(m.Header["Maze"], 10)
+	m.Width = headerInt(m.Header["Size X"], 10)
+	m.Height = headerInt(m.Header["Size Y"], 10)
+	m.Length = headerInt(m.Header["Length"], 10)
+	m.Offset = headerInt(strings.SplitN(m.Header["File offset"], ",", 2)[0], 16)
+	m.End = headerInt(m.Header["End"], 16)
+}
+
+func headerInt(value string, base int) int {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), base, 64)
+	if err != nil {
+		return -1
+	}
+	return int(n)
 }
 
 //LoadLevel - loading all the maps from a file
 func LoadLevel(file string) ([]string, error) {
-	var level []string
-
 	f, err := pkger.Open(file)
 	if err != nil {
-		return level, err
+		return nil, err
 	}
 	defer f.Close()
+	return readLines(f)
+}
+
+// LoadPack - loading and parsing a pack from disk in whichever supported format it is written
+func LoadPack(file string) ([]Level, error) {
+	lines, err := LoadLevelFile(file)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := ParsePack(lines)
+	if err == nil && len(levels) == 0 {
+		err = fmt.Errorf("no levels found in %s", file)
+	}
+	return levels, err
+}
+
+// ParsePack - parsing a pack in whichever format packFormat detects
+func ParsePack(lines []string) ([]Level, error) {
+	switch packFormat(lines) {
+	case "native":
+		return ParseLevels(lines), nil
+	case "slc":
+		return ParseSLC(lines)
+	case "json":
+		return ParseJSON(lines)
+	}
+	return ParseXSB(lines), nil
+}
 
-	scan := bufio.NewScanner(f)
+// packFormat tells SLC collections by their XML, JSON packs by their braces, and the native format
+// from XSB by its "Maze: N" headers
+func packFormat(lines []string) string {
+	if isSLC(lines) {
+		return "slc"
+	}
+	if isJSONPack(lines) {
+		return "json"
+	}
+	for _, line := range lines {
+		if mazeHeader.MatchString(line) {
+			return "native"
+		}
+	}
+	return "xsb"
+}
+
+func newLevelMeta(n int) LevelMeta {
+	return LevelMeta{
+		Number: n,
+		Width:  -1, Height: -1,
+		Offset: -1, End: -1,
+		Length: -1,
+		Header: map[string]string{},
+	}
+}
+
+// LoadLevelFile - loading all the maps from a file on disk, or the bundled pack when file is empty
+func LoadLevelFile(file string) ([]string, error) {
+	if file == "" {
+		return LoadLevel(bundledPack)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLines(f)
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var level []string
+	scan := bufio.NewScanner(r)
 	for scan.Scan() {
-		line := scan.Text()
-		level = append(level, line)
+		level = append(level, scan.Text())
+	}
+	return level, scan.Err()
+}
+
+// WriteLevels - write levels in the native "Maze: N" pack format
+func WriteLevels(w io.Writer, levels []Level) error {
+	for i, l := range levels {
+		if _, err := fmt.Fprintf(w, "\nMaze: %d\n", i); err != nil {
+			return err
+		}
+		for _, key := range headerKeys(l.Meta.Header) {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", key, l.Meta.Header[key]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+		grid := l.Grid
+		if isHexLevel(l) {
+			grid = expandHexGrid(grid)
+		}
+		for _, line := range grid {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var headerOrder = []string{"File offset", "Size X", "Size Y", "End", "Length"}
+
+func headerKeys(header map[string]string) []string {
+	var keys, extra []string
+	for _, key := range headerOrder {
+		if _, ok := header[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	for key := range header {
+		known := key == "Maze"
+		for _, k := range headerOrder {
+			known = known || k == key
+		}
+		if !known {
+			extra = append(extra, key)
+		}
 	}
-	return level, nil
+	sort.Strings(extra)
+	return append(keys, extra...)
 }
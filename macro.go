@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/danicat/simpleansi"
+)
+
+// macroSelect - the cursor used to pick a boulder and the cell to push it to
+type macroSelect struct {
+	active  bool
+	x, y    int
+	box     *Boulder
+	message string
+}
+
+var selection macroSelect
+
+// macroState - the boulder being pushed and the player, during the search for a push path
+type macroState struct {
+	box, player cell
+}
+
+type macroStep struct {
+	from macroState
+	move Move
+}
+
+// startSelection puts the cursor on the player, ready to pick a boulder
+func startSelection() {
+	if hexGrid || extendedRules {
+		selection.message = "macro pushes need a square level without extended tiles"
+		return
+	}
+	if mode == pullMode {
+		selection.message = "macro pushes and walks can't be used in reverse mode, where every step pulls"
+		return
+	}
+	selection = macroSelect{active: true, x: player.X, y: player.Y}
+}
+
+// macroKey handles a key while the cursor is shown: arrows move it, Enter on a boulder picks it and
+// then its destination, Enter on a floor cell walks there, ESC cancels
+func macroKey(level []string, evt string) {
+	selection.message = ""
+	switch evt {
+	case "ESC":
+		selection = macroSelect{}
+	case "ENTER":
+		to := cell{selection.x, selection.y}
+		var path []Move
+		ok := false
+		switch {
+		case selection.box != nil:
+			path, ok = pushPath(level, selection.box, to)
+			selection = macroSelect{}
+			if !ok {
+				selection.message = "no way to push the boulder there without moving another"
+				return
+			}
+		case getBoulderAtPosition(to.X, to.Y) != nil:
+			selection.box = getBoulderAtPosition(to.X, to.Y)
+			return
+		default:
+			path, ok = walkPath(level, to)
+			selection = macroSelect{}
+			if !ok {
+				selection.message = "no way to walk there"
+				return
+			}
+		}
+		var steps []Step
+		for _, m := range path {
+			steps = append(steps, Step{Who: current, Dir: m})
+		}
+		if !redoRecorder.IsEmpty() {
+			saveBranch()
+			redoRecorder.Reset()
+		}
+		animate(level, steps)
+	default:
+		if dir, ok := keys[evt]; ok {
+			x, y := moves[dir](level, selection.x, selection.y)
+			if x >= 0 && x < len(level) && y >= 0 && y < len(level[x]) {
+				selection.x, selection.y = x, y
+			}
+		}
+	}
+}
+
+// walkPath finds the fewest moves walking the player to a cell around the boulders
+func walkPath(level []string, to cell) ([]Move, bool) {
+	start := cell{player.X, player.Y}
+	came := map[cell]macroStep{start: {}}
+	queue := []cell{start}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c == to {
+			var path []Move
+			for c != start {
+				step := came[c]
+				path = append([]Move{step.move}, path...)
+				c = step.from.player
+			}
+			return path, true
+		}
+		for _, d := range directions {
+			next := cell{c.X + d.dx, c.Y + d.dy}
+			if _, seen := came[next]; seen || next.X < 0 || next.X >= len(level) || next.Y < 0 || next.Y >= len(level[next.X]) {
+				continue
+			}
+			if isWallTile(level, next.X, next.Y) || getBoulderAtPosition(next.X, next.Y) != nil || playerAtPosition(next.X, next.Y) {
+				continue
+			}
+			came[next] = macroStep{macroState{player: c}, d.move}
+			queue = append(queue, next)
+		}
+	}
+	return nil, false
+}
+
+// pushPath finds the fewest moves that push a boulder onto a cell, walking the player around
+// and leaving the other boulders where they are
+func pushPath(level []string, b *Boulder, to cell) ([]Move, bool) {
+	blocked := func(c cell) bool {
+		if c.X < 0 || c.X >= len(level) || c.Y < 0 || c.Y >= len(level[c.X]) || isWallTile(level, c.X, c.Y) {
+			return true
+		}
+		if other := getBoulderAtPosition(c.X, c.Y); other != nil && other != b {
+			return true
+		}
+		return playerAtPosition(c.X, c.Y) && c != cell{player.X, player.Y}
+	}
+	start := macroState{cell{b.X, b.Y}, cell{player.X, player.Y}}
+	came := map[macroState]macroStep{start: {}}
+	queue := []macroState{start}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if s.box == to {
+			var path []Move
+			for s != start {
+				step := came[s]
+				path = append([]Move{step.move}, path...)
+				s = step.from
+			}
+			return path, true
+		}
+		for _, d := range directions {
+			next := macroState{s.box, cell{s.player.X + d.dx, s.player.Y + d.dy}}
+			if next.player == s.box {
+				next.box = cell{s.box.X + d.dx, s.box.Y + d.dy}
+				if blocked(next.box) || isCorner(level, next.box.X, next.box.Y) && next.box != to {
+					continue
+				}
+			} else if blocked(next.player) {
+				continue
+			}
+			if _, seen := came[next]; seen {
+				continue
+			}
+			came[next] = macroStep{s, d.move}
+			queue = append(queue, next)
+		}
+	}
+	return nil, false
+}
+
+// drawSelection shows the cursor, and the picked boulder, over the map
+func drawSelection() {
+	if selection.box != nil {
+		simpleansi.MoveCursor(selection.box.X, screenColumn(selection.box.X, selection.box.Y))
+		fmt.Print(simpleansi.WithBackground("*", simpleansi.MAGENTA))
+	}
+	if selection.active {
+		simpleansi.MoveCursor(selection.x, screenColumn(selection.x, selection.y))
+		fmt.Print(simpleansi.WithBlueBackground("+"))
+	}
+}
+
+func selectionStatus() string {
+	switch {
+	case selection.active && selection.box == nil:
+		return "move the cursor to a boulder, or a cell to walk to, and press Enter, ESC to cancel"
+	case selection.active:
+		return "move the cursor to where the boulder should go and press Enter, ESC to cancel"
+	}
+	return selection.message
+}
@@ -1,44 +1,88 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/danicat/simpleansi"
-	"github.com/google/uuid"
 	"github.com/markbates/pkger"
 )
 
 var keys = map[string]Move{
-	"A": up,
-	"B": down,
-	"C": right,
-	"D": left,
+	"UP":    up,
+	"DOWN":  down,
+	"RIGHT": right,
+	"LEFT":  left,
 }
 
-var player Player
+var arrows = map[byte]string{
+	'A': "UP",
+	'B': "DOWN",
+	'C': "RIGHT",
+	'D': "LEFT",
+}
+
+var player *Player
+var players []*Player
+var current int
 var boulders []*Boulder
 var targets []*Target
-var flightRecorder PairStack
-
-func initBlackbox() *PairStack {
-	flightRecorder = PairStack{}
-	flightRecorder.New()
+var flightRecorder MoveLog
+var redoRecorder MoveLog
+
+// startPosition is where the pieces stood when the level began, the point the move log replays from
+var startPosition Position
+
+// undos counts the moves taken back on the current level
+var undos int
+
+func initBlackbox() *MoveLog {
+	flightRecorder.Reset()
+	redoRecorder.Reset()
+	undos = 0
+	branches = nil
+	bookmarks = map[int]Bookmark{}
+	marking = false
+	startPosition = takePosition()
 	return &flightRecorder
 }
 
-func initPlayer(level []string) Player {
+func initPlayers(level []string) []*Player {
+	var players []*Player
 	for x, line := range level {
 		for y, char := range line {
 			switch char {
-			case '@':
-				return Player{x, y}
+			case '@', '+':
+				players = append(players, &Player{x, y})
 			}
 		}
 	}
-	return Player{}
+	if len(players) == 0 {
+		players = append(players, &Player{})
+	}
+	return players
+}
+
+// selectPlayer makes the i-th pusher the one that moves
+func selectPlayer(i int) {
+	if i >= 0 && i < len(players) {
+		current = i
+		player = players[i]
+	}
+}
+
+func playerAtPosition(x int, y int) bool {
+	for _, p := range players {
+		if p.X == x && p.Y == y {
+			return true
+		}
+	}
+	return false
 }
 
 func initBoulder(level []string) []*Boulder {
@@ -46,8 +90,8 @@ func initBoulder(level []string) []*Boulder {
 	for x, line := range level {
 		for y, char := range line {
 			switch char {
-			case '*':
-				boulders = append(boulders, &Boulder{x, y, uuid.New()})
+			case '*', '&':
+				boulders = append(boulders, &Boulder{x, y, pieceID("box", x, y)})
 			}
 		}
 	}
@@ -59,8 +103,8 @@ func initTarget(level []string) []*Target {
 	for x, line := range level {
 		for y, char := range line {
 			switch char {
-			case '.':
-				targets = append(targets, &Target{x, y, uuid.New()})
+			case '.', '&', '+':
+				targets = append(targets, &Target{x, y, pieceID("target", x, y)})
 			}
 		}
 	}
@@ -84,34 +128,90 @@ func printMaps(maps [][]string) {
 var reset = "\x1b[0m"
 var oo = "\x1b[42m" + " " + reset
 
-func printMap(maps [][]string, idx int) {
+func printMap(levels []Level, idx int) {
 	simpleansi.ClearScreen()
-	for _, line := range maps[idx] {
-		for _, chr := range line {
+	grid := levels[idx].Grid
+	for x, line := range grid {
+		if hexGrid && x%2 == 1 {
+			fmt.Print(" ")
+		}
+		for y, chr := range line {
+			if hexGrid && y > 0 {
+				fmt.Print(" ")
+			}
 			switch chr {
 			case 'X':
-				fmt.Print(simpleansi.WithBackground(" ", simpleansi.GREEN))
-			case '.':
-				fmt.Printf("%c", chr)
+				fmt.Print(simpleansi.WithBackground(" ", themes[profile.Theme].Terminal))
 			default:
-				fmt.Print(" ")
+				if b := behaviorAt(grid, x, y); b != nil {
+					fmt.Print(b.Glyph(grid))
+				} else {
+					fmt.Print(" ")
+				}
 			}
 		}
 		fmt.Println()
 	}
 
+	for _, t := range targets {
+		simpleansi.MoveCursor(t.X, screenColumn(t.X, t.Y))
+		fmt.Print(".")
+	}
 	for _, b := range boulders {
-		simpleansi.MoveCursor(b.X, b.Y)
+		simpleansi.MoveCursor(b.X, screenColumn(b.X, b.Y))
 		fmt.Print("*")
 	}
-	simpleansi.MoveCursor(player.X, player.Y)
-	fmt.Print("@")
-	simpleansi.MoveCursor(len(maps[idx])+1, 0)
+	for _, p := range players {
+		simpleansi.MoveCursor(p.X, screenColumn(p.X, p.Y))
+		if p == player && len(players) > 1 {
+			fmt.Print(simpleansi.WithBlueBackground("@"))
+		} else {
+			fmt.Print("@")
+		}
+	}
+	drawSelection()
+	simpleansi.MoveCursor(len(grid)+1, 0)
+	printStatus(levels[idx].Meta)
+}
+
+func printStatus(meta LevelMeta) {
+	fmt.Printf("Maze %d", meta.Number)
+	if title := meta.Header["Title"]; title != "" {
+		fmt.Printf(" - %s", title)
+	}
+	if by := meta.Header["Copyright"]; by != "" {
+		fmt.Printf(" (%s)", by)
+	}
+	if mode == pullMode {
+		fmt.Print("  reverse")
+	}
+	if hexGrid {
+		fmt.Print("  hex: q e / a d / z c")
+	}
+	if len(players) > 1 {
+		fmt.Printf("  pusher %d/%d (Tab to switch)", current+1, len(players))
+	}
+	if meta.Width > 0 && meta.Height > 0 {
+		fmt.Printf("  %dx%d", meta.Width, meta.Height)
+	}
+	if meta.Length > 0 {
+		fmt.Printf("  length %d", meta.Length)
+	}
+	fmt.Println()
+	if status := challenge.status(); status != "" {
+		fmt.Println(status)
+	}
+	if status := bookmarkStatus(); status != "" {
+		fmt.Println(status)
+	}
+	if status := selectionStatus(); status != "" {
+		fmt.Println(status)
+	}
 }
 
 func readInput() (string, error) {
 	buffer := make([]byte, 100)
-	cnt, err := os.Stdin.Read(buffer)
+	cnt, err := keyboard.Read(buffer)
 	if err != nil {
 		return "", err
 	}
@@ -122,11 +222,20 @@ func readInput() (string, error) {
 		if buffer[0] == 0x7f {
 			return "BACKSPACE", nil
 		}
+		if buffer[0] == '\t' {
+			return "TAB", nil
+		}
+		if buffer[0] == '\n' || buffer[0] == '\r' {
+			return "ENTER", nil
+		}
+		if buffer[0] >= ' ' && buffer[0] < 0x7f {
+			return string(buffer[0]), nil
+		}
 	}
 
 	if cnt >= 3 {
 		if buffer[0] == 0x1b && buffer[1] == '[' {
-			return string(buffer[2]), nil
+			return arrows[buffer[2]], nil
 		}
 	}
 	return "", nil
@@ -156,6 +265,10 @@ const (
 	left
 	right
 	none
+	northWest
+	northEast
+	southWest
+	southEast
 )
 
 var moves = map[Move]func(level []string, x int, y int) (int, int){
@@ -163,6 +276,11 @@ var moves = map[Move]func(level []string, x int, y int) (int, int){
 	down:  moveDown,
 	left:  moveLeft,
 	right: moveRight,
+
+	northWest: moveNorthWest,
+	northEast: moveNorthEast,
+	southWest: moveSouthWest,
+	southEast: moveSouthEast,
 }
 
 func isPositionOfOccupied(level []string, x int, y int, positionMarker byte) bool {
@@ -181,7 +299,7 @@ func hitWall(level []string, x int, y int) bool {
 }
 
 func isPositionOccupied(level []string, x int, y int) bool {
-	return hitWall(level, x, y) || isPositionOfOccupied(level, x, y, '*')
+	return hitWall(level, x, y) || isPositionOfOccupied(level, x, y, '*') || playerAtPosition(x, y)
 }
 
 func calculateMove(level []string, fromX int, fromY int, direction Move) (toX int, toY int) {
@@ -189,8 +307,11 @@ func calculateMove(level []string, fromX int, fromY int, direction Move) (toX in
 		return
 	}
 	if moveFunc, ok := moves[direction]; ok {
+		if mode == pullMode {
+			return calculatePull(level, fromX, fromY, direction)
+		}
 		toX, toY = moveFunc(level, fromX, fromY)
-		if hitWall(level, toX, toY) {
+		if hitWall(level, toX, toY) || playerAtPosition(toX, toY) || !canEnter(level, toX, toY, direction) {
 			toX = fromX
 			toY = fromY
 		}
@@ -198,44 +319,50 @@ func calculateMove(level []string, fromX int, fromY int, direction Move) (toX in
 		b := getBoulderAtPosition(toX, toY)
 		if b != nil {
 			candX, candY := moveFunc(level, toX, toY)
-			if isPositionOccupied(level, candX, candY) {
-				b.X, b.Y = toX, toY
+			if isPositionOccupied(level, candX, candY) || !canEnter(level, candX, candY, direction) {
 				toX, toY = fromX, fromY
 			} else {
-				b.X, b.Y = candX, candY
+				boulderX, boulderY := arrive(level, candX, candY, direction, true)
+				moveBoulder(b, boulderX, boulderY)
 			}
 		}
-		recordFlight(toX, toY, b)
+		moved := toX != fromX || toY != fromY
+		if moved {
+			toX, toY = arrive(level, toX, toY, direction, false)
+		}
+		recordStep(direction, moved, b != nil)
 
 	}
 	return
 }
 
-func recordFlight(pX int, pY int, b *Boulder) {
-	p := Player{pX, pY}
-	var t Boulder
-	if b != nil {
-		t = Boulder{b.X, b.Y, b.ID}
+// recordStep logs a move of the current pusher; bumping into something is not logged
+func recordStep(direction Move, moved bool, pushed bool) {
+	if moved {
+		flightRecorder.Push(Step{current, direction, pushed})
+	}
+}
+
+func stepKey(direction Move, moved bool, boulder bool) byte {
+	key, ok := stepKeys[direction]
+	if !moved || !ok {
+		return 0
+	}
+	if boulder {
+		return key - 'a' + 'A'
 	}
-	flightRecorder.Push(Pair{p, t})
+	return key
 }
 
 func getBoulderAtPosition(x int, y int) *Boulder {
-	for _, cand := range boulders {
-		if cand.X == x && cand.Y == y {
-			return cand
-		}
+	if c := pieces.cell(x, y); c >= 0 {
+		return pieces.boulders[c]
 	}
 	return nil
 }
 
 func boulderAtPosition(x int, y int) bool {
-	for _, cand := range boulders {
-		if cand.X == x && cand.Y == y {
-			return true
-		}
-	}
-	return false
+	return getBoulderAtPosition(x, y) != nil
 }
 
 func movePlayer(level []string, dir Move) {
@@ -244,59 +371,317 @@ func movePlayer(level []string, dir Move) {
 	}
 }
 
-func moveBack(pair *Pair) {
-	if pair != nil {
-		player.X, player.Y = pair.P.X, pair.P.Y
-		b := getBoulderByID(pair.B.ID)
-		if b != nil {
-			b.X, b.Y = pair.B.X, pair.B.Y
+func undo(level []string) {
+	steps, ok := flightRecorder.PopGroup()
+	if ok {
+		redoRecorder.PushGroup(steps)
+		if stepsReverse() {
+			for _, s := range steps {
+				stepBack(level, s)
+			}
+		} else {
+			replayLog(level)
 		}
+		undos++
 	}
 }
 
-func getBoulderByID(id uuid.UUID) *Boulder {
-	for _, cand := range boulders {
-		if cand.ID == id {
-			return cand
+// stepsReverse reports whether a logged move can be taken back from the move alone: pushing on a square
+// level without extended tiles, where nothing slides, teleports or depends on where the boxes stood
+func stepsReverse() bool {
+	return !hexGrid && !extendedRules && mode == pushMode
+}
+
+// stepBack takes back one move of a pusher, pulling back the boulder it pushed
+func stepBack(level []string, s Step) {
+	if s.Who >= len(players) {
+		return
+	}
+	p := players[s.Who]
+	if s.Pushed {
+		boulderX, boulderY := moves[s.Dir](level, p.X, p.Y)
+		if b := getBoulderAtPosition(boulderX, boulderY); b != nil {
+			moveBoulder(b, p.X, p.Y)
 		}
 	}
-	return nil
+	p.X, p.Y = moves[opposite[s.Dir]](level, p.X, p.Y)
+}
+
+// redo plays an undone move, or an undone group of moves, again
+func redo(level []string) {
+	steps, ok := redoRecorder.PopGroup()
+	if ok {
+		playGroup(level, steps)
+	}
 }
 
-func matchBoulderToTarget(b *Boulder, t *Target) bool {
-	return b.X == t.X && b.Y == t.Y
+// playGroup plays moves at once as one action, undone in one go
+func playGroup(level []string, steps []Step) {
+	animation = animationQueue{steps: steps, logged: flightRecorder.Bytes(), before: len(flightRecorder.Steps())}
+	animation.skip(level)
 }
 
-func isLevelCompleted() bool {
-	c := 0
+// replayLog works out the current position by putting the pieces back where the level started
+// and playing the logged moves again
+func replayLog(level []string) {
+	logged := flightRecorder.Bytes()
+	steps := flightRecorder.Steps()
+	active := current
+	startPosition.restore(level)
+	flightRecorder.Reset()
+	for _, s := range steps {
+		selectPlayer(s.Who)
+		movePlayer(level, s.Dir)
+	}
+	selectPlayer(active)
+	flightRecorder.LoadBytes(logged)
+}
+
+func takePosition() Position {
+	var p Position
+	for _, pl := range players {
+		p.Players = append(p.Players, *pl)
+	}
 	for _, b := range boulders {
-		for _, t := range targets {
-			if matchBoulderToTarget(b, t) {
-				c++
-			}
-		}
+		p.Boulders = append(p.Boulders, *b)
+	}
+	return p
+}
+
+// restore moves every pusher and boulder back to a position taken on the same level
+func (p Position) restore(level []string) {
+	for i, pl := range p.Players {
+		*players[i] = pl
+	}
+	for i, b := range p.Boulders {
+		*boulders[i] = b
 	}
-	return c == len(boulders)
+	indexPieces(level)
+}
+
+func isLevelCompleted() bool {
+	return pieces.placed == len(boulders)
 }
 
-func initLevel(allLevels []string, startLevel int) ([][]string, []string) {
-	maps := ParseLevel(allLevels)
-	level := maps[startLevel]
-	player = initPlayer(level)
+func initLevel(levels []Level, idx int) []string {
+	level := levels[idx].Grid
+	hexGrid = isHexLevel(levels[idx])
+	extendedRules = isExtendedLevel(levels[idx])
+	players = initPlayers(level)
+	selectPlayer(0)
 	targets = initTarget(level)
 	boulders = initBoulder(level)
+	indexPieces(level)
+	if mode == pullMode {
+		initReverse(level)
+	}
 	initBlackbox()
-	return maps, level
+	return level
 }
 
 func main() {
-	Initialise()
 	pkger.Include("/levels/maps.txt")
-	allLevels, _ := LoadLevel("/levels/maps.txt")
+	if Dispatch(os.Args[1:]) {
+		return
+	}
+	play()
+}
+
+func play() {
+	Initialise()
 	defer Cleanup()
-	startLevel := 0
-	maps, level := initLevel(allLevels, startLevel)
+	allLevels, err := LoadLevel(bundledPack)
+	if err != nil {
+		log.Println("Error loading levels:", err)
+		return
+	}
+	input := startInput()
+	if err := startProfile("", input); err != nil {
+		log.Println("Error loading profile:", err)
+		return
+	}
+	runMenu(ParseLevels(allLevels), input)
+}
+
+// runMenu is the start screen: play on from the profile's progress, see statistics or switch profile
+func runMenu(levels []Level, input <-chan string) {
+	for {
+		simpleansi.ClearScreen()
+		fmt.Printf("Sokobango - %s\n\n", profile.Name)
+		header := levels[0].Meta.Header
+		if header["Collection"] != "" {
+			fmt.Println(header["Collection"])
+			if by := header["Collection copyright"]; by != "" {
+				fmt.Println("by", by)
+			}
+			fmt.Println()
+		}
+		next := resumeLevel(len(levels))
+		fmt.Printf("  Enter  play from maze %d", next)
+		if title := levels[next].Meta.Header["Title"]; title != "" {
+			fmt.Printf(" - %s", title)
+		}
+		fmt.Println()
+		fmt.Println("  s      statistics")
+		fmt.Println("  p      switch profile")
+		fmt.Println("  q      quit")
+		switch <-input {
+		case "ENTER":
+			runGame(levels, resumeLevel(len(levels)), input)
+		case "s":
+			showStats(input)
+		case "p":
+			names, _ := profileNames()
+			if name, err := pickProfile(names, input); err == nil {
+				if err := loadProfile(name); err != nil {
+					log.Println("Error loading profile:", err)
+				}
+			}
+		case "q", "ESC":
+			simpleansi.ClearScreen()
+			return
+		}
+	}
+}
+
+// resumeLevel - where the profile left off in the current pack
+func resumeLevel(count int) int {
+	if next := profile.Progress[currentPack]; next < count {
+		return next
+	}
+	return 0
+}
+
+func runPlay(args []string) error {
+	fs := flag.NewFlagSet("play", flag.ContinueOnError)
+	pack := fs.String("pack", "", "level pack to play, - for stdin (default the bundled pack)")
+	levelString := fs.String("level-string", "", "play one maze given in the run-length encoded one-line notation")
+	startLevel := fs.Int("level", -1, "level to start from, skipping the menu (default where the profile left off)")
+	reverse := fs.Bool("reverse", false, "reverse mode: start solved and pull the boxes back to their starting places")
+	fs.BoolVar(&forceExtended, "extended", false, "extended rules for every level, not just those with a \"Rules: extended\" header")
+	fs.StringVar(&solutionDir, "solutions", "", "directory to save the lurd solution of every completed level")
+	challengeName := fs.String("challenge", "", "challenge mode: time, moves or hardcore")
+	fs.IntVar(&challenge.limit, "limit", 0, "seconds for time attack, or moves for the move limit (default par)")
+	speed := fs.Int("speed", 60, "milliseconds per move when walks and macro pushes are animated, 0 for none")
+	profileName := fs.String("profile", "", "player profile (default ask when there are several)")
+	themeName := fs.String("theme", "", "colour theme to keep in the profile: "+strings.Join(themeNames(), ", "))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 1 && fs.Arg(0) == "-" {
+		*pack = "-"
+	} else if fs.NArg() > 0 {
+		return errors.New("usage: sokobango play [flags] [-]")
+	}
+	var ok bool
+	if challenge.mode, ok = challengeModes[*challengeName]; !ok {
+		return fmt.Errorf("unknown challenge %q", *challengeName)
+	}
+	var levels []Level
+	var err error
+	if *levelString != "" {
+		if *pack != "" {
+			return errors.New("-pack and -level-string can't be used together")
+		}
+		var l Level
+		if l, err = ParseLevelString(*levelString); err == nil {
+			levels = []Level{l}
+			err = checkPlayable(levels)
+		}
+		*startLevel = 0
+		currentPack = "level string"
+	} else if *pack == "-" {
+		levels, err = readPipedLevels()
+		if *startLevel < 0 {
+			*startLevel = 0
+		}
+		currentPack = "stdin"
+	} else {
+		levels, err = LoadPack(*pack)
+	}
+	if err != nil {
+		return err
+	}
+	if *startLevel >= len(levels) {
+		return fmt.Errorf("level %d out of range, the pack has %d levels", *startLevel, len(levels))
+	}
+	if _, ok := themes[*themeName]; *themeName != "" && !ok {
+		return fmt.Errorf("unknown theme %q", *themeName)
+	}
+	if *pack != "" && *pack != "-" {
+		currentPack = *pack
+	}
+	animationDelay = time.Duration(*speed) * time.Millisecond
+	if *reverse {
+		mode = pullMode
+	}
+	Initialise()
+	defer Cleanup()
+	input := startInput()
+	if err := startProfile(*profileName, input); err != nil {
+		return err
+	}
+	if *themeName != "" {
+		profile.Theme = *themeName
+		if err := saveProfile(); err != nil {
+			return err
+		}
+	}
+	if *startLevel < 0 {
+		runMenu(levels, input)
+		return nil
+	}
+	runGame(levels, *startLevel, input)
+	return nil
+}
 
+// checkPlayable refuses levels that lint finds broken, for levels that come from outside a pack file:
+// a maze without walls all around or without a player would crash the game
+func checkPlayable(levels []Level) error {
+	for i, l := range levels {
+		hexGrid = isHexLevel(l)
+		extendedRules = isExtendedLevel(l)
+		if issues := LintLevel(i, l); len(issues) > 0 {
+			return fmt.Errorf("maze %d can't be played: %s", i, issues[0].Message)
+		}
+	}
+	return nil
+}
+
+// readPipedLevels reads a pack in any format, or a single level string, from stdin, checks it can be
+// played and switches the keyboard to the terminal, as stdin is used up
+func readPipedLevels() ([]Level, error) {
+	lines, err := readLines(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	var text []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			text = append(text, line)
+		}
+	}
+	var levels []Level
+	if len(text) == 1 {
+		if l, err := ParseLevelString(text[0]); err == nil {
+			levels = []Level{l}
+		}
+	}
+	if levels == nil {
+		if levels, err = ParsePack(lines); err != nil {
+			return nil, err
+		}
+		if len(levels) == 0 {
+			return nil, errors.New("no levels found on stdin")
+		}
+	}
+	if err := checkPlayable(levels); err != nil {
+		return nil, err
+	}
+	return levels, useTerminalKeyboard()
+}
+
+func startInput() <-chan string {
 	input := make(chan string)
 	go func(ch chan<- string) {
 		for {
@@ -308,36 +693,126 @@ func main() {
 			ch <- input
 		}
 	}(input)
+	return input
+}
+
+// handleKey applies a key press to the current pusher
+func handleKey(level []string, evt string) {
+	if evt == "BACKSPACE" && challenge.allowUndo() {
+		undo(level)
+	}
+	if evt == "r" && challenge.allowUndo() {
+		redo(level)
+	}
+	if dirMove, ok := moveKeys()[evt]; ok {
+		branchMove(level, dirMove)
+	}
+}
+
+// beginLevel sets up a level for play, picking up a casual game where it was left
+func beginLevel(levels []Level, idx int) []string {
+	level := initLevel(levels, idx)
+	challenge.start(levels[idx], idx)
+	if challenge.mode == casual && !playTesting {
+		resumeGame(levels[idx], level)
+	}
+	return level
+}
+
+// runGame - the game loop, playing from startLevel until the last level is completed or ESC is pressed,
+// reporting whether the last level was completed
+func runGame(levels []Level, startLevel int, input <-chan string) bool {
+	level := beginLevel(levels, startLevel)
 	exit := false
 	// game loop
 	for {
+		if msg, ok := challenge.playable(); !ok {
+			fmt.Println(msg + ", press any key")
+			<-input
+			return false
+		}
 
 		// process movement
 		select {
 		case evt := <-input:
+			if animation.busy() {
+				animation.skip(level)
+				break
+			}
+			if selection.active {
+				macroKey(level, evt)
+				break
+			}
+			selection.message = ""
+			if evt == "g" {
+				startSelection()
+				break
+			}
 			if evt == "ESC" {
 				exit = true
 			}
-			if evt == "BACKSPACE" {
-				moveBack(flightRecorder.Pop())
+			if evt == "TAB" {
+				selectPlayer((current + 1) % len(players))
 			}
-			if dirMove, ok := keys[evt]; ok {
-				movePlayer(level, dirMove)
+			if evt == "b" && challenge.allowUndo() {
+				showBranches(level, input)
 			}
+			if challenge.allowUndo() && bookmarkKey(levels[startLevel], level, evt) {
+				break
+			}
+			handleKey(level, evt)
 		default:
+			animation.step(level)
 		}
 
-		printMap(maps, startLevel)
+		printMap(levels, startLevel)
+		if animation.busy() {
+			time.Sleep(animationDelay)
+			continue
+		}
 
 		if exit {
-			break
+			if !playTesting {
+				recordSession(levels[startLevel], false)
+			}
+			if challenge.mode == casual && !playTesting {
+				saveGame(levels[startLevel])
+			}
+			return false
+		}
+
+		// is the challenge lost
+		if msg, failed := challenge.failed(); failed {
+			recordSession(levels[startLevel], false)
+			if err := challenge.finish(levels[startLevel], false); err != nil {
+				log.Println("Error saving result:", err)
+			}
+			level = beginLevel(levels, startLevel)
+			challenge.message = msg + ", level restarted"
 		}
 
 		// is completed
-		if isLevelCompleted() {
+		if levelCompleted(level) {
 			fmt.Println("Level completed")
+			if !playTesting {
+				dropGame(levels[startLevel])
+				if err := exportSolution(levels[startLevel], level); err != nil {
+					log.Println("Error saving solution:", err)
+				}
+				if err := challenge.finish(levels[startLevel], true); err != nil {
+					log.Println("Error saving result:", err)
+				}
+				recordSession(levels[startLevel], true)
+				recordProgress(startLevel + 1)
+				if scoredPlay(levels[startLevel]) {
+					showScores(levels[startLevel], level, input)
+				}
+			}
 			startLevel++
-			maps, level = initLevel(allLevels, startLevel)
+			if startLevel >= len(levels) {
+				return true
+			}
+			level = beginLevel(levels, startLevel)
 		}
 
 		// repeat
@@ -17,3 +17,24 @@ type Boulder struct {
 	X, Y int
 	ID   uuid.UUID
 }
+
+// Position - where every pusher and boulder stands at one point of a level
+type Position struct {
+	Players  []Player
+	Boulders []Boulder
+}
+
+// LevelMeta - the header data declared above a maze, -1 where missing or unreadable
+type LevelMeta struct {
+	Number        int
+	Width, Height int
+	Offset, End   int
+	Length        int
+	Header        map[string]string
+}
+
+// Level - a maze grid together with its metadata
+type Level struct {
+	Meta LevelMeta
+	Grid []string
+}
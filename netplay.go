@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danicat/simpleansi"
+)
+
+// netEvent - a key pressed by the client controlling pusher who
+type netEvent struct {
+	who int
+	key string
+}
+
+// server - the host of a cooperative game; it runs the engine and sends every client the board
+type server struct {
+	lock    sync.Mutex
+	clients map[int]net.Conn
+	pushers int // pushers in the level being played, one for the host and one per client
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":7777", "address to listen on")
+	pack := fs.String("pack", "", "level pack to play (default the bundled pack)")
+	startLevel := fs.Int("level", 0, "level to start from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	levels, err := LoadPack(*pack)
+	if err != nil {
+		return err
+	}
+	if *startLevel < 0 || *startLevel >= len(levels) {
+		return fmt.Errorf("level %d out of range, the pack has %d levels", *startLevel, len(levels))
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	srv := &server{clients: map[int]net.Conn{}, pushers: len(initPlayers(levels[*startLevel].Grid))}
+	events := make(chan netEvent)
+	go srv.accept(ln, events)
+	Initialise()
+	defer Cleanup()
+	srv.run(levels, *startLevel, startInput(), events)
+	srv.closeAll()
+	return nil
+}
+
+// accept hands each new client the lowest free pusher, the host always being pusher 0, and turns
+// clients away once every pusher of the level is taken
+func (s *server) accept(ln net.Listener, events chan<- netEvent) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		who := 1
+		for s.clients[who] != nil {
+			who++
+		}
+		if who >= s.pushers {
+			fmt.Fprintf(conn, "FULL all %d pushers of the level are taken\n", s.pushers)
+			conn.Close()
+			s.lock.Unlock()
+			continue
+		}
+		// greeted before it is registered, so a broadcast can't send the board first
+		fmt.Fprintf(conn, "HELLO %d\n", who)
+		s.clients[who] = conn
+		s.lock.Unlock()
+		go func(who int, conn net.Conn) {
+			scan := bufio.NewScanner(conn)
+			for scan.Scan() {
+				events <- netEvent{who, scan.Text()}
+			}
+			events <- netEvent{who, "BYE"}
+		}(who, conn)
+		events <- netEvent{who, "JOIN"}
+	}
+}
+
+func (s *server) run(levels []Level, idx int, local <-chan string, events <-chan netEvent) {
+	level := s.initLevel(levels, idx)
+	changed := true
+	for {
+		select {
+		case evt := <-local:
+			if evt == "ESC" {
+				return
+			}
+			changed = s.handle(level, netEvent{0, evt}) || changed
+		case evt := <-events:
+			changed = s.handle(level, evt) || changed
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		printMap(levels, idx)
+		if changed {
+			s.broadcast(levels[idx])
+			changed = false
+		}
+
+		if levelCompleted(level) {
+			fmt.Println("Level completed")
+			idx++
+			if idx >= len(levels) {
+				s.send("DONE")
+				return
+			}
+			level = s.initLevel(levels, idx)
+			changed = true
+		}
+	}
+}
+
+// initLevel starts a level and lets accept know how many pushers it has room for
+func (s *server) initLevel(levels []Level, idx int) []string {
+	level := initLevel(levels, idx)
+	s.lock.Lock()
+	s.pushers = len(players)
+	s.lock.Unlock()
+	return level
+}
+
+// handle applies a client's key to its own pusher, reporting whether the board may have changed
+func (s *server) handle(level []string, evt netEvent) bool {
+	switch evt.key {
+	case "JOIN":
+		return true
+	case "BYE":
+		s.lock.Lock()
+		if conn := s.clients[evt.who]; conn != nil {
+			conn.Close()
+		}
+		delete(s.clients, evt.who)
+		s.lock.Unlock()
+		return false
+	}
+	if evt.who >= len(players) {
+		return false
+	}
+	selectPlayer(evt.who)
+	handleKey(level, evt.key)
+	return true
+}
+
+// broadcast sends the board with every pusher drawn as a letter, a for pusher 0, b for pusher 1 and so on
+func (s *server) broadcast(l Level) {
+	grid := snapshotGrid(l.Grid)
+	for i, p := range players {
+		if i < 10 {
+			grid[p.X] = setTile(grid[p.X], p.Y, byte('a'+i))
+		}
+	}
+	var frame strings.Builder
+	frame.WriteString("FRAME\n")
+	if hexGrid {
+		frame.WriteString("HEX\n")
+	}
+	for _, line := range grid {
+		frame.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&frame, "STATUS Maze %d  %d pushers\nEND", l.Meta.Number, len(players))
+	s.send(frame.String())
+}
+
+func (s *server) send(msg string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for who, conn := range s.clients {
+		if _, err := fmt.Fprintln(conn, msg); err != nil {
+			log.Println("Error sending to pusher", who, err)
+			conn.Close()
+			delete(s.clients, who)
+		}
+	}
+}
+
+func (s *server) closeAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for who, conn := range s.clients {
+		conn.Close()
+		delete(s.clients, who)
+	}
+}
+
+func runJoin(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: sokobango join host:port")
+	}
+	conn, err := net.Dial("tcp", args[0])
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	scan := bufio.NewScanner(conn)
+	if !scan.Scan() {
+		return errors.New("not a sokobango server")
+	}
+	if strings.HasPrefix(scan.Text(), "FULL ") {
+		return fmt.Errorf("game full: %s", strings.TrimPrefix(scan.Text(), "FULL "))
+	}
+	if !strings.HasPrefix(scan.Text(), "HELLO ") {
+		return errors.New("not a sokobango server")
+	}
+	me, err := strconv.Atoi(strings.TrimPrefix(scan.Text(), "HELLO "))
+	if err != nil {
+		return err
+	}
+
+	frames := make(chan []string)
+	go func() {
+		var frame []string
+		for scan.Scan() {
+			line := scan.Text()
+			switch line {
+			case "FRAME":
+				frame = nil
+			case "END":
+				frames <- frame
+			case "DONE":
+				frames <- []string{"STATUS All levels completed"}
+			default:
+				frame = append(frame, line)
+			}
+		}
+		close(frames)
+	}()
+
+	Initialise()
+	defer Cleanup()
+	input := startInput()
+	for {
+		select {
+		case key := <-input:
+			if key == "ESC" {
+				return nil
+			}
+			if _, err := fmt.Fprintln(conn, key); err != nil {
+				return err
+			}
+		case frame, ok := <-frames:
+			if !ok {
+				fmt.Println("Disconnected")
+				return nil
+			}
+			drawFrame(frame, me)
+		}
+	}
+}
+
+// drawFrame prints a board received from the server, highlighting our own pusher
+func drawFrame(frame []string, me int) {
+	simpleansi.ClearScreen()
+	hex := false
+	row := 0
+	for _, line := range frame {
+		if line == "HEX" {
+			hex = true
+			continue
+		}
+		if strings.HasPrefix(line, "STATUS ") {
+			fmt.Printf("\n%s  you are pusher %d\n", strings.TrimPrefix(line, "STATUS "), me)
+			continue
+		}
+		if hex {
+			line = strings.Repeat(" ", row%2) + strings.Join(strings.Split(line, ""), " ")
+		}
+		row++
+		for _, chr := range line {
+			switch {
+			case chr == 'X':
+				fmt.Print(simpleansi.WithBackground(" ", simpleansi.GREEN))
+			case chr == rune('a'+me):
+				fmt.Print(simpleansi.WithBlueBackground("@"))
+			case chr >= 'a' && chr <= 'j':
+				fmt.Print("@")
+			case chr == '&':
+				fmt.Print("*")
+			default:
+				fmt.Print(string(chr))
+			}
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const jsonPackVersion = 1
+
+// jsonPack - a pack as JSON, for tools that would rather not parse level text; see the README for the schema
+type jsonPack struct {
+	Format  string      `json:"format"`
+	Version int         `json:"version"`
+	Levels  []jsonLevel `json:"levels"`
+}
+
+type jsonLevel struct {
+	Number int               `json:"number"`
+	Hash   string            `json:"hash"`
+	Width  int               `json:"width"`
+	Height int               `json:"height"`
+	Header map[string]string `json:"header,omitempty"`
+	Grid   []string          `json:"grid"`
+}
+
+// isJSONPack tells a JSON pack by its opening brace
+func isJSONPack(lines []string) bool {
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			return strings.HasPrefix(line, "{")
+		}
+	}
+	return false
+}
+
+// ParseJSON - reading a pack written by WriteJSON
+func ParseJSON(lines []string) ([]Level, error) {
+	var pack jsonPack
+	if err := json.Unmarshal([]byte(strings.Join(lines, "\n")), &pack); err != nil {
+		return nil, err
+	}
+	if pack.Format != "sokobango" || pack.Version > jsonPackVersion {
+		return nil, fmt.Errorf("not a sokobango pack of version %d or older", jsonPackVersion)
+	}
+	var levels []Level
+	for _, l := range pack.Levels {
+		meta := newLevelMeta(l.Number)
+		for key, value := range l.Header {
+			meta.Header[key] = value
+		}
+		meta.parseHeader()
+		meta.Number = l.Number
+		levels = append(levels, Level{Meta: meta, Grid: l.Grid})
+	}
+	return levels, nil
+}
+
+// WriteJSON - write levels as an indented JSON pack
+func WriteJSON(w io.Writer, levels []Level) error {
+	pack := jsonPack{Format: "sokobango", Version: jsonPackVersion, Levels: []jsonLevel{}}
+	for i, l := range levels {
+		level := jsonLevel{Number: i, Hash: LevelHash(l, false), Header: map[string]string{}, Grid: l.Grid}
+		level.Width, level.Height = gridSize(l.Grid)
+		for key, value := range l.Meta.Header {
+			if key != "Maze" {
+				level.Header[key] = value
+			}
+		}
+		pack.Levels = append(pack.Levels, level)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pack)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danicat/simpleansi"
+)
+
+// Profile - one person's settings and progress, kept in its own directory under the data directory
+type Profile struct {
+	Name     string            `json:"name"`
+	Theme    string            `json:"theme"`
+	Keys     map[string]string `json:"keys"`
+	Progress map[string]int    `json:"progress"`
+}
+
+// Session - one attempt at a level, the record lifetime statistics are computed from
+type Session struct {
+	Pack      string    `json:"pack"`
+	Level     int       `json:"level"`
+	Hash      string    `json:"hash"`
+	Mode      string    `json:"mode"`
+	Completed bool      `json:"completed"`
+	Moves     int       `json:"moves"`
+	Pushes    int       `json:"pushes"`
+	Undos     int       `json:"undos"`
+	Seconds   float64   `json:"seconds"`
+	When      time.Time `json:"when"`
+}
+
+const (
+	defaultProfile = "default"
+	profileFile    = "profile.json"
+	sessionsFile   = "sessions.jsonl"
+)
+
+var profile = Profile{Name: defaultProfile, Theme: "classic", Progress: map[string]int{}}
+
+var moveNames = map[string]Move{
+	"up":    up,
+	"down":  down,
+	"left":  left,
+	"right": right,
+}
+
+// reservedKeys - keys the game loop handles itself, besides the bookmark slots 1 to 9
+var reservedKeys = map[string]bool{
+	"ESC": true, "BACKSPACE": true, "ENTER": true, "TAB": true,
+	"r": true, "b": true, "g": true, "m": true,
+}
+
+// reservedKey reports whether a key already does something in the game, so it can't be bound to a move
+func reservedKey(key string) bool {
+	return reservedKeys[key] || len(key) == 1 && key[0] >= '1' && key[0] <= '9'
+}
+
+var validProfileName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,16}$`)
+
+// profilePath - a file of the current profile, relative to its directory
+func profilePath(name string) string {
+	return filepath.Join("profiles", profile.Name, name)
+}
+
+func profileNames() ([]string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "profiles"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// loadProfile makes a profile current, creating it on first use, and applies its settings
+func loadProfile(name string) error {
+	if err := readProfile(name); err != nil {
+		return err
+	}
+	return saveProfile()
+}
+
+// readProfile makes a profile current without writing it, for commands that only look at it
+func readProfile(name string) error {
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("bad profile name %q, use up to 16 letters, digits, - or _", name)
+	}
+	for key := range profile.Keys {
+		delete(keys, key)
+	}
+	for moveName, move := range moveNames {
+		keys[strings.ToUpper(moveName)] = move
+	}
+	profile = Profile{Name: name, Theme: "classic"}
+	if err := readJSON(profilePath(profileFile), &profile); err != nil {
+		return err
+	}
+	if profile.Progress == nil {
+		profile.Progress = map[string]int{}
+	}
+	if _, ok := themes[profile.Theme]; !ok {
+		return fmt.Errorf("profile %s: unknown theme %q", name, profile.Theme)
+	}
+	for key, moveName := range profile.Keys {
+		if reservedKey(key) {
+			return fmt.Errorf("profile %s: key %q is used by the game and can't be bound to a move", name, key)
+		}
+		move, ok := moveNames[moveName]
+		if !ok {
+			return fmt.Errorf("profile %s: key %q bound to unknown move %q", name, key, moveName)
+		}
+		keys[key] = move
+	}
+	return nil
+}
+
+func saveProfile() error {
+	return writeJSON(profilePath(profileFile), profile)
+}
+
+// recordProgress remembers the next level to play in the current pack
+func recordProgress(next int) {
+	if next <= profile.Progress[currentPack] {
+		return
+	}
+	profile.Progress[currentPack] = next
+	if err := saveProfile(); err != nil {
+		log.Println("Error saving profile:", err)
+	}
+}
+
+// recordSession appends the attempt just finished or abandoned to the profile's sessions
+func recordSession(l Level, completed bool) {
+	history := moveHistory()
+	s := Session{
+		Pack:      currentPack,
+		Level:     l.Meta.Number,
+		Hash:      LevelHash(l, false),
+		Mode:      challengeNames[challenge.mode],
+		Completed: completed,
+		Moves:     len(history),
+		Pushes:    countPushes(history),
+		Undos:     undos,
+		Seconds:   challenge.elapsed().Seconds(),
+		When:      time.Now(),
+	}
+	if s.Moves == 0 && !completed {
+		return
+	}
+	if err := appendJSON(profilePath(sessionsFile), s); err != nil {
+		log.Println("Error saving session:", err)
+	}
+}
+
+// startProfile loads the named profile, asking who is playing when no name is given
+func startProfile(name string, input <-chan string) error {
+	if name == "" {
+		var err error
+		if name, err = chooseProfile(input); err != nil {
+			return err
+		}
+	}
+	return loadProfile(name)
+}
+
+// chooseProfile asks which profile to play when several exist, or lets a new one be named
+func chooseProfile(input <-chan string) (string, error) {
+	names, err := profileNames()
+	if err != nil || len(names) == 0 {
+		return defaultProfile, err
+	}
+	if len(names) == 1 {
+		return names[0], nil
+	}
+	return pickProfile(names, input)
+}
+
+// pickProfile lists the profiles to choose from by number, or n to name a new one
+func pickProfile(names []string, input <-chan string) (string, error) {
+	var typed []byte
+	naming := false
+	for {
+		simpleansi.ClearScreen()
+		fmt.Println("Who is playing?")
+		for i, name := range names {
+			if i < 9 {
+				fmt.Printf("  %d  %s\n", i+1, name)
+			}
+		}
+		fmt.Println("  n  new profile")
+		if naming {
+			fmt.Printf("\nname: %s", typed)
+		}
+		evt := <-input
+		switch {
+		case evt == "ESC":
+			return "", errors.New("no profile chosen")
+		case naming && evt == "ENTER":
+			if validProfileName.Match(typed) {
+				return string(typed), nil
+			}
+		case naming && evt == "BACKSPACE":
+			if len(typed) > 0 {
+				typed = typed[:len(typed)-1]
+			}
+		case naming && len(evt) == 1:
+			typed = append(typed, evt[0])
+		case evt == "n":
+			naming = true
+		case len(evt) == 1 && evt[0] >= '1' && int(evt[0]-'0') <= len(names) && evt[0] <= '9':
+			return names[evt[0]-'1'], nil
+		}
+	}
+}
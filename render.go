@@ -0,0 +1,465 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/danicat/simpleansi"
+)
+
+// Theme - the colours used to draw a level
+type Theme struct {
+	Background color.RGBA
+	Wall       color.RGBA
+	Floor      color.RGBA
+	Goal       color.RGBA
+	Box        color.RGBA
+	BoxOnGoal  color.RGBA
+	Player     color.RGBA
+	Ice        color.RGBA
+	Special    color.RGBA
+	Gate       color.RGBA
+	Terminal   simpleansi.Colour
+}
+
+// teleporterColours tell the pairs of teleporters apart, the same in every theme
+var teleporterColours = []color.RGBA{
+	{230, 60, 60, 255}, {60, 180, 230, 255}, {230, 160, 40, 255},
+	{160, 80, 220, 255}, {60, 200, 120, 255}, {230, 90, 180, 255},
+	{140, 140, 40, 255}, {40, 120, 140, 255}, {150, 90, 60, 255},
+}
+
+var themes = map[string]Theme{
+	"classic": {
+		Background: color.RGBA{0, 0, 0, 255},
+		Wall:       color.RGBA{0, 170, 0, 255},
+		Floor:      color.RGBA{24, 24, 24, 255},
+		Goal:       color.RGBA{220, 220, 220, 255},
+		Box:        color.RGBA{200, 140, 40, 255},
+		BoxOnGoal:  color.RGBA{240, 200, 60, 255},
+		Player:     color.RGBA{60, 120, 230, 255},
+		Ice:        color.RGBA{150, 210, 230, 255},
+		Special:    color.RGBA{170, 170, 170, 255},
+		Gate:       color.RGBA{150, 100, 50, 255},
+		Terminal:   simpleansi.GREEN,
+	},
+	"paper": {
+		Background: color.RGBA{255, 255, 255, 255},
+		Wall:       color.RGBA{60, 60, 60, 255},
+		Floor:      color.RGBA{240, 236, 226, 255},
+		Goal:       color.RGBA{200, 60, 60, 255},
+		Box:        color.RGBA{160, 110, 60, 255},
+		BoxOnGoal:  color.RGBA{90, 160, 90, 255},
+		Player:     color.RGBA{40, 80, 180, 255},
+		Ice:        color.RGBA{200, 230, 245, 255},
+		Special:    color.RGBA{120, 120, 120, 255},
+		Gate:       color.RGBA{130, 80, 40, 255},
+		Terminal:   simpleansi.BROWN,
+	},
+	"mono": {
+		Background: color.RGBA{255, 255, 255, 255},
+		Wall:       color.RGBA{0, 0, 0, 255},
+		Floor:      color.RGBA{255, 255, 255, 255},
+		Goal:       color.RGBA{128, 128, 128, 255},
+		Box:        color.RGBA{96, 96, 96, 255},
+		BoxOnGoal:  color.RGBA{32, 32, 32, 255},
+		Player:     color.RGBA{0, 0, 0, 255},
+		Ice:        color.RGBA{224, 224, 224, 255},
+		Special:    color.RGBA{160, 160, 160, 255},
+		Gate:       color.RGBA{64, 64, 64, 255},
+		Terminal:   simpleansi.GREY,
+	},
+}
+
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	pack := fs.String("pack", "", "level pack to read (default the bundled pack)")
+	levelIdx := fs.Int("level", 0, "level number within the pack")
+	format := fs.String("format", "png", "output format: svg, png or gif")
+	themeName := fs.String("theme", "classic", "colour theme: "+strings.Join(themeNames(), ", "))
+	cellSize := fs.Int("cell", 24, "size of one cell in pixels")
+	position := fs.String("moves", "", "lurd moves to play before drawing; for gif, the moves to animate")
+	delay := fs.Int("delay", 15, "gif frame delay in hundredths of a second")
+	out := fs.String("o", "", "output file (default level-N.<format>)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	theme, ok := themes[*themeName]
+	if !ok {
+		return fmt.Errorf("unknown theme %q", *themeName)
+	}
+	if *format != "svg" && *format != "png" && *format != "gif" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *cellSize < 4 {
+		return errors.New("cell size must be at least 4 pixels")
+	}
+	levels, err := LoadPack(*pack)
+	if err != nil {
+		return err
+	}
+	if *levelIdx < 0 || *levelIdx >= len(levels) {
+		return fmt.Errorf("level %d out of range, the pack has %d levels", *levelIdx, len(levels))
+	}
+	level := levels[*levelIdx].Grid
+	hexGrid = isHexLevel(levels[*levelIdx])
+	extendedRules = isExtendedLevel(levels[*levelIdx])
+
+	moves := *position
+	if *format == "gif" && moves == "" {
+		moves = levels[*levelIdx].Meta.Header["Solution"]
+	}
+	if *format == "gif" && moves == "" {
+		if hexGrid {
+			return errors.New("the solver only handles square levels, pass the moves to animate")
+		}
+		if moves, err = SolveLevel(level); err != nil {
+			return err
+		}
+	}
+	positions, err := Replay(level, moves)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		*out = fmt.Sprintf("level-%d.%s", *levelIdx, *format)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := renderer{theme: theme, cell: *cellSize, inside: insideCells(level), level: level}
+	switch *format {
+	case "svg":
+		err = r.svg(f, positions[len(positions)-1])
+	case "png":
+		err = png.Encode(f, r.image(positions[len(positions)-1]))
+	case "gif":
+		err = r.gif(f, positions, *delay)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("wrote", *out)
+	return nil
+}
+
+func themeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// insideCells are the cells within the walls, so the outside is drawn as background rather than floor
+func insideCells(level []string) map[cell]bool {
+	level = trimLevel(level)
+	for x, line := range level {
+		for y, char := range line {
+			if char == '@' || char == '+' {
+				reach, _ := floodFill(level, cell{x, y})
+				return reach
+			}
+		}
+	}
+	return map[cell]bool{}
+}
+
+type renderer struct {
+	theme  Theme
+	cell   int
+	inside map[cell]bool
+	level  []string
+}
+
+type shape int
+
+const (
+	fillCell shape = iota
+	goalMark
+	boxMark
+	playerMark
+	arrowMark
+	ringMark
+	frameMark
+	barsMark
+)
+
+type mark struct {
+	shape  shape
+	colour color.RGBA
+	dir    Move
+}
+
+// marks lists what to draw in a cell, bottom layer first
+func (r renderer) marks(grid []string, x int, y int) []mark {
+	char := grid[x][y]
+	if char == 'X' {
+		return []mark{{fillCell, r.theme.Wall, none}}
+	}
+	if !r.inside[cell{x, y}] {
+		return nil
+	}
+	marks := []mark{{fillCell, r.theme.Floor, none}}
+	if extendedRules {
+		marks = append(marks, r.tileMarks(grid, x, y)...)
+	}
+	switch char {
+	case '.', '+':
+		marks = append(marks, mark{goalMark, r.theme.Goal, none})
+	case '*':
+		marks = append(marks, mark{boxMark, r.theme.Box, none})
+	case '&':
+		marks = append(marks, mark{boxMark, r.theme.BoxOnGoal, none})
+	}
+	if char == '@' || char == '+' {
+		marks = append(marks, mark{playerMark, r.theme.Player, none})
+	}
+	return marks
+}
+
+// tileMarks draws the special floor of the extended rules, read from the level itself where a box or
+// the player stands on it
+func (r renderer) tileMarks(grid []string, x int, y int) []mark {
+	tile := grid[x][y]
+	if _, ok := tileBehaviors[tile]; !ok {
+		tile = tileAt(r.level, x, y)
+	}
+	switch t := tileBehaviors[tile].(type) {
+	case iceTile:
+		return []mark{{fillCell, r.theme.Ice, none}}
+	case arrowTile:
+		return []mark{{arrowMark, r.theme.Special, t.dir}}
+	case teleporterTile:
+		return []mark{{ringMark, teleporterColours[t.pair-'1'], none}}
+	case plateTile:
+		return []mark{{frameMark, r.theme.Special, none}}
+	case gateTile:
+		if r.gatesOpen(grid) {
+			return []mark{{frameMark, r.theme.Gate, none}}
+		}
+		return []mark{{barsMark, r.theme.Gate, none}}
+	}
+	return nil
+}
+
+// gatesOpen reports whether a box stands on every pressure plate of a drawn position
+func (r renderer) gatesOpen(grid []string) bool {
+	for x, line := range r.level {
+		for y := range line {
+			if line[y] == '_' && tileAt(grid, x, y) != '*' && tileAt(grid, x, y) != '&' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// arrowPoints - the corners of the triangle pointing dir inside a cell, in pixels from its top left
+func (r renderer) arrowPoints(dir Move) [3]image.Point {
+	lo, mid, hi := r.cell/4, r.cell/2, r.cell*3/4
+	switch dir {
+	case up:
+		return [3]image.Point{{mid, lo}, {hi, hi}, {lo, hi}}
+	case down:
+		return [3]image.Point{{mid, hi}, {lo, lo}, {hi, lo}}
+	case left:
+		return [3]image.Point{{lo, mid}, {hi, lo}, {hi, hi}}
+	}
+	return [3]image.Point{{hi, mid}, {lo, hi}, {lo, lo}}
+}
+
+// origin is the pixel position of a cell's top left corner, odd rows of hex levels shifted half a cell
+func (r renderer) origin(x int, y int) (int, int) {
+	left := y * r.cell
+	if hexGrid && x%2 == 1 {
+		left += r.cell / 2
+	}
+	return x * r.cell, left
+}
+
+func (r renderer) size(grid []string) (int, int) {
+	width, height := gridSize(grid)
+	if hexGrid {
+		return width*r.cell + r.cell/2, height * r.cell
+	}
+	return width * r.cell, height * r.cell
+}
+
+func (r renderer) image(grid []string) *image.RGBA {
+	width, height := r.size(grid)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill(img, img.Bounds(), r.theme.Background)
+	for x, line := range grid {
+		for y := range line {
+			for _, m := range r.marks(grid, x, y) {
+				r.drawMark(img, x, y, m)
+			}
+		}
+	}
+	return img
+}
+
+func (r renderer) drawMark(img *image.RGBA, x int, y int, m mark) {
+	top, left := r.origin(x, y)
+	box := image.Rect(left, top, left+r.cell, top+r.cell)
+	switch m.shape {
+	case fillCell:
+		fill(img, box, m.colour)
+	case goalMark:
+		fill(img, box.Inset(r.cell*3/8), m.colour)
+	case boxMark:
+		fill(img, box.Inset(r.cell/8), m.colour)
+	case playerMark:
+		radius := r.cell * 3 / 8
+		cx, cy := left+r.cell/2, top+r.cell/2
+		for py := cy - radius; py <= cy+radius; py++ {
+			for px := cx - radius; px <= cx+radius; px++ {
+				if (px-cx)*(px-cx)+(py-cy)*(py-cy) <= radius*radius {
+					img.Set(px, py, m.colour)
+				}
+			}
+		}
+	case ringMark:
+		outer, inner := r.cell*3/8, r.cell/4
+		cx, cy := left+r.cell/2, top+r.cell/2
+		for py := cy - outer; py <= cy+outer; py++ {
+			for px := cx - outer; px <= cx+outer; px++ {
+				if d := (px-cx)*(px-cx) + (py-cy)*(py-cy); d <= outer*outer && d >= inner*inner {
+					img.Set(px, py, m.colour)
+				}
+			}
+		}
+	case frameMark:
+		edge := r.cell / 8
+		in := box.Inset(edge)
+		fill(img, image.Rect(in.Min.X, in.Min.Y, in.Max.X, in.Min.Y+edge), m.colour)
+		fill(img, image.Rect(in.Min.X, in.Max.Y-edge, in.Max.X, in.Max.Y), m.colour)
+		fill(img, image.Rect(in.Min.X, in.Min.Y, in.Min.X+edge, in.Max.Y), m.colour)
+		fill(img, image.Rect(in.Max.X-edge, in.Min.Y, in.Max.X, in.Max.Y), m.colour)
+	case barsMark:
+		for _, bar := range r.bars() {
+			fill(img, bar.Add(image.Pt(left, top)), m.colour)
+		}
+	case arrowMark:
+		p := r.arrowPoints(m.dir)
+		for py := 0; py < r.cell; py++ {
+			for px := 0; px < r.cell; px++ {
+				if inTriangle(image.Pt(px, py), p) {
+					img.Set(left+px, top+py, m.colour)
+				}
+			}
+		}
+	}
+}
+
+// bars - the upright bars of a closed gate, relative to its cell
+func (r renderer) bars() []image.Rectangle {
+	width := r.cell / 8
+	var bars []image.Rectangle
+	for i := 1; i <= 3; i++ {
+		x := i*r.cell/4 - width/2
+		bars = append(bars, image.Rect(x, 0, x+width, r.cell))
+	}
+	return bars
+}
+
+// inTriangle reports whether a point lies inside a triangle or on its edges
+func inTriangle(pt image.Point, t [3]image.Point) bool {
+	side := func(a, b image.Point) int {
+		return (b.X-a.X)*(pt.Y-a.Y) - (b.Y-a.Y)*(pt.X-a.X)
+	}
+	d1, d2, d3 := side(t[0], t[1]), side(t[1], t[2]), side(t[2], t[0])
+	negative := d1 < 0 || d2 < 0 || d3 < 0
+	positive := d1 > 0 || d2 > 0 || d3 > 0
+	return !(negative && positive)
+}
+
+func fill(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	for py := rect.Min.Y; py < rect.Max.Y; py++ {
+		for px := rect.Min.X; px < rect.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+func (r renderer) svg(w io.Writer, grid []string) error {
+	width, height := r.size(grid)
+	hex := func(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", width, height)
+	fmt.Fprintf(&b, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", hex(r.theme.Background))
+	for x, line := range grid {
+		for y := range line {
+			top, left := r.origin(x, y)
+			for _, m := range r.marks(grid, x, y) {
+				switch m.shape {
+				case fillCell:
+					fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", left, top, r.cell, r.cell, hex(m.colour))
+				case goalMark:
+					inset := r.cell * 3 / 8
+					fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", left+inset, top+inset, r.cell-2*inset, r.cell-2*inset, hex(m.colour))
+				case boxMark:
+					inset := r.cell / 8
+					fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", left+inset, top+inset, r.cell-2*inset, r.cell-2*inset, hex(m.colour))
+				case playerMark:
+					fmt.Fprintf(&b, "<circle cx=\"%d\" cy=\"%d\" r=\"%d\" fill=\"%s\"/>\n", left+r.cell/2, top+r.cell/2, r.cell*3/8, hex(m.colour))
+				case ringMark:
+					fmt.Fprintf(&b, "<circle cx=\"%d\" cy=\"%d\" r=\"%d\" fill=\"none\" stroke=\"%s\" stroke-width=\"%d\"/>\n", left+r.cell/2, top+r.cell/2, r.cell*5/16, hex(m.colour), r.cell/8)
+				case frameMark:
+					edge := r.cell / 8
+					fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"none\" stroke=\"%s\" stroke-width=\"%d\"/>\n", left+edge+edge/2, top+edge+edge/2, r.cell-3*edge, r.cell-3*edge, hex(m.colour), edge)
+				case barsMark:
+					for _, bar := range r.bars() {
+						fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", left+bar.Min.X, top+bar.Min.Y, bar.Dx(), bar.Dy(), hex(m.colour))
+					}
+				case arrowMark:
+					p := r.arrowPoints(m.dir)
+					fmt.Fprintf(&b, "<polygon points=\"%d,%d %d,%d %d,%d\" fill=\"%s\"/>\n", left+p[0].X, top+p[0].Y, left+p[1].X, top+p[1].Y, left+p[2].X, top+p[2].Y, hex(m.colour))
+				}
+			}
+		}
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r renderer) gif(w io.Writer, positions [][]string, delay int) error {
+	t := r.theme
+	palette := color.Palette{t.Background, t.Wall, t.Floor, t.Goal, t.Box, t.BoxOnGoal, t.Player, t.Ice, t.Special, t.Gate}
+	for _, c := range teleporterColours {
+		palette = append(palette, c)
+	}
+	anim := &gif.GIF{}
+	for i, grid := range positions {
+		img := r.image(grid)
+		frame := image.NewPaletted(img.Bounds(), palette)
+		for py := img.Bounds().Min.Y; py < img.Bounds().Max.Y; py++ {
+			for px := img.Bounds().Min.X; px < img.Bounds().Max.X; px++ {
+				frame.Set(px, py, img.RGBAAt(px, py))
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+		if i == len(positions)-1 {
+			anim.Delay = append(anim.Delay, delay*10)
+		} else {
+			anim.Delay = append(anim.Delay, delay)
+		}
+	}
+	return gif.EncodeAll(w, anim)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stepKeys - the lurd letter of each move, with q, e, z and c for the diagonal moves of hex levels
+var stepKeys = map[Move]byte{
+	up:        'u',
+	down:      'd',
+	left:      'l',
+	right:     'r',
+	northWest: 'q',
+	northEast: 'e',
+	southWest: 'z',
+	southEast: 'c',
+}
+
+var lurdMoves = map[rune]Move{
+	'u': up,
+	'd': down,
+	'l': left,
+	'r': right,
+	'q': northWest,
+	'e': northEast,
+	'z': southWest,
+	'c': southEast,
+}
+
+// Replay - play a lurd solution through the engine from the start of a level, returning the grid after every step
+func Replay(level []string, solution string) ([][]string, error) {
+	level = trimLevel(level)
+	players = initPlayers(level)
+	selectPlayer(0)
+	targets = initTarget(level)
+	boulders = initBoulder(level)
+	indexPieces(level)
+	initBlackbox()
+	positions := [][]string{snapshotGrid(level)}
+	for i, c := range solution {
+		dir, ok := lurdMoves[c|0x20]
+		if !ok {
+			return positions, fmt.Errorf("move %d: %q is not a lurd move", i+1, c)
+		}
+		toX, toY := moves[dir](level, player.X, player.Y)
+		pushed := getBoulderAtPosition(toX, toY) != nil
+		before := *player
+		movePlayer(level, dir)
+		if *player == before {
+			return positions, fmt.Errorf("move %d: %q is blocked", i+1, c)
+		}
+		if c >= 'A' && c <= 'Z' && !pushed {
+			return positions, fmt.Errorf("move %d: %q does not push a boulder", i+1, c)
+		}
+		positions = append(positions, snapshotGrid(level))
+	}
+	return positions, nil
+}
+
+// snapshotGrid draws the current players and boulders over the empty level
+func snapshotGrid(level []string) []string {
+	clear := strings.NewReplacer("*", " ", "&", ".", "@", " ", "+", ".")
+	grid := make([]string, len(level))
+	for x, line := range level {
+		grid[x] = clear.Replace(line)
+	}
+	for _, b := range boulders {
+		if grid[b.X][b.Y] == '.' {
+			grid[b.X] = setTile(grid[b.X], b.Y, '&')
+		} else {
+			grid[b.X] = setTile(grid[b.X], b.Y, '*')
+		}
+	}
+	for _, p := range players {
+		if grid[p.X][p.Y] == '.' {
+			grid[p.X] = setTile(grid[p.X], p.Y, '+')
+		} else {
+			grid[p.X] = setTile(grid[p.X], p.Y, '@')
+		}
+	}
+	return grid
+}
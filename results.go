@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Result - the outcome of one attempt at a level
+type Result struct {
+	Pack      string    `json:"pack"`
+	Profile   string    `json:"profile"`
+	Level     int       `json:"level"`
+	Hash      string    `json:"hash"`
+	Mode      string    `json:"mode"`
+	Completed bool      `json:"completed"`
+	Moves     int       `json:"moves"`
+	Pushes    int       `json:"pushes"`
+	Seconds   float64   `json:"seconds"`
+	Score     int       `json:"score"`
+	Solution  string    `json:"solution,omitempty"`
+	When      time.Time `json:"when"`
+}
+
+// currentPack names the pack being played in stored results
+var currentPack = "bundled"
+
+// dataDir - where sokobango keeps its files, $SOKOBANGO_HOME or the user's config directory
+func dataDir() (string, error) {
+	dir := os.Getenv("SOKOBANGO_HOME")
+	if dir == "" {
+		config, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(config, "sokobango")
+	}
+	return dir, os.MkdirAll(dir, 0o755)
+}
+
+// dataFile - the path of a file in the data directory, creating the directory it lives in
+func dataFile(name string) (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name)
+	return file, os.MkdirAll(filepath.Dir(file), 0o755)
+}
+
+// appendJSON adds a value as one JSON line to a file in the data directory
+func appendJSON(name string, v interface{}) error {
+	file, err := dataFile(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(v)
+}
+
+// readJSONLines calls each with every line of a JSON lines file in the data directory
+func readJSONLines(name string, each func(line []byte) error) error {
+	file, err := dataFile(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scan := bufio.NewScanner(f)
+	scan.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scan.Scan() {
+		if err := each(scan.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scan.Err()
+}
+
+// readJSON decodes a file in the data directory, leaving v untouched when the file does not exist yet
+func readJSON(name string, v interface{}) error {
+	file, err := dataFile(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON replaces a file in the data directory, writing a temporary file first so a crash cannot truncate it
+func writeJSON(name string, v interface{}) error {
+	file, err := dataFile(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file+".tmp", append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
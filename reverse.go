@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// GameMode - the rule used to move boulders
+type GameMode int
+
+const (
+	pushMode GameMode = iota
+	pullMode
+)
+
+var mode GameMode
+
+// reverseStart is where the player began in the forward level, which must be reachable again to finish in reverse
+var reverseStart Player
+
+var solutionDir string
+
+var opposite = map[Move]Move{
+	up:        down,
+	down:      up,
+	left:      right,
+	right:     left,
+	northWest: southEast,
+	southEast: northWest,
+	northEast: southWest,
+	southWest: northEast,
+}
+
+var oppositeKey = map[byte]byte{
+	'u': 'd',
+	'd': 'u',
+	'l': 'r',
+	'r': 'l',
+	'q': 'c',
+	'c': 'q',
+	'e': 'z',
+	'z': 'e',
+}
+
+// calculatePull moves the player onto a free cell, dragging along a boulder standing right behind
+func calculatePull(level []string, fromX int, fromY int, direction Move) (int, int) {
+	toX, toY := moves[direction](level, fromX, fromY)
+	if isPositionOccupied(level, toX, toY) || !canEnter(level, toX, toY, direction) {
+		return fromX, fromY
+	}
+	backX, backY := moves[opposite[direction]](level, fromX, fromY)
+	b := getBoulderAtPosition(backX, backY)
+	if b != nil && !canEnter(level, fromX, fromY, direction) {
+		b = nil
+	}
+	if b != nil {
+		moveBoulder(b, fromX, fromY)
+	}
+	recordStep(direction, true, b != nil)
+	return arrive(level, toX, toY, direction, false)
+}
+
+// initReverse swaps boulders and targets so the level starts solved, moving the player off a boulder if needed
+func initReverse(level []string) {
+	reverseStart = *player
+	goals := targets
+	targets = nil
+	for _, b := range boulders {
+		targets = append(targets, &Target{b.X, b.Y, pieceID("target", b.X, b.Y)})
+	}
+	boulders = nil
+	for _, g := range goals {
+		boulders = append(boulders, &Boulder{g.X, g.Y, pieceID("box", g.X, g.Y)})
+	}
+	indexPieces(level)
+	if getBoulderAtPosition(player.X, player.Y) == nil {
+		return
+	}
+	reach, _ := floodFill(level, cell{player.X, player.Y})
+	for x, line := range level {
+		for y := range line {
+			if reach[cell{x, y}] && getBoulderAtPosition(x, y) == nil && !playerAtPosition(x, y) {
+				*player = Player{x, y}
+				return
+			}
+		}
+	}
+}
+
+func levelCompleted(level []string) bool {
+	if !isLevelCompleted() {
+		return false
+	}
+	if mode != pullMode {
+		return true
+	}
+	_, ok := walkTo(level, *player, reverseStart)
+	return ok
+}
+
+// walkTo finds the lurd walk between two cells around the current boulders
+func walkTo(level []string, from Player, to Player) (string, bool) {
+	p, err := newPuzzle(level)
+	if err != nil {
+		return "", false
+	}
+	occupied := make([]bool, len(p.walls))
+	for _, b := range boulders {
+		occupied[p.index(b.X, b.Y)] = true
+	}
+	return p.walk(p.index(from.X, from.Y), p.index(to.X, to.Y), occupied)
+}
+
+// moveHistory - the lurd moves recorded by the flight recorder
+func moveHistory() string {
+	var lurd []byte
+	for _, s := range flightRecorder.Steps() {
+		lurd = append(lurd, stepKey(s.Dir, true, s.Pushed))
+	}
+	return string(lurd)
+}
+
+// forwardSolution turns a finished reverse game into a forward solution: walk from the start to where
+// the pulling ended, then replay every pull backwards as a push, dropping the walk after the last push
+func forwardSolution(level []string) string {
+	walk, _ := walkTo(level, reverseStart, *player)
+	history := moveHistory()
+	solution := []byte(walk)
+	for i := len(history) - 1; i >= 0; i-- {
+		step := oppositeKey[history[i]|0x20]
+		if history[i] >= 'A' && history[i] <= 'Z' {
+			step = step - 'a' + 'A'
+		}
+		solution = append(solution, step)
+	}
+	for len(solution) > 0 && solution[len(solution)-1] >= 'a' {
+		solution = solution[:len(solution)-1]
+	}
+	return string(solution)
+}
+
+// finalSolution - the forward solution of a completed level, whichever way it was played
+func finalSolution(level []string) string {
+	if mode == pullMode {
+		return forwardSolution(level)
+	}
+	return moveHistory()
+}
+
+// solutionReplays reports whether the moves of the level being played can be stored as lurd:
+// lurd has no way of telling which pusher moved, so multi-pusher solutions are not kept
+func solutionReplays() bool {
+	return len(players) == 1
+}
+
+// exportSolution saves the forward solution of a completed level as <level hash>.lurd, in the solutions
+// directory or else the profile's
+func exportSolution(l Level, level []string) error {
+	if !solutionReplays() {
+		return nil
+	}
+	dir := solutionDir
+	if dir == "" {
+		var err error
+		if dir, err = dataFile(profilePath("solutions")); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	file := filepath.Join(dir, LevelHash(l, false)+".lurd")
+	return os.WriteFile(file, []byte(finalSolution(level)+"\n"), 0o644)
+}
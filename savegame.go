@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const savesFile = "saves.json"
+
+// Bookmark - a position marked within a level, with the moves that led to it
+type Bookmark struct {
+	Moves    []byte   `json:"moves"`
+	Position Position `json:"position"`
+}
+
+// SaveGame - an unfinished level of a profile: the moves played, the bookmarks set and the time spent
+type SaveGame struct {
+	Moves     []byte           `json:"moves"`
+	Bookmarks map[int]Bookmark `json:"bookmarks,omitempty"`
+	Seconds   float64          `json:"seconds,omitempty"`
+	When      time.Time        `json:"when"`
+}
+
+// bookmarks are the marked positions of the current level, slots 1 to 9
+var bookmarks = map[int]Bookmark{}
+
+// marking is set after m is pressed, while waiting for the slot to mark
+var marking bool
+
+// saveKey - the level hash, apart for reverse games which have their own saves
+func saveKey(l Level) string {
+	if mode == pullMode {
+		return LevelHash(l, false) + "/reverse"
+	}
+	return LevelHash(l, false)
+}
+
+func loadSaves() (map[string]SaveGame, error) {
+	saves := map[string]SaveGame{}
+	err := readJSON(profilePath(savesFile), &saves)
+	return saves, err
+}
+
+// saveGame stores the moves and bookmarks of the current level so it can be picked up again
+func saveGame(l Level) {
+	saves, err := loadSaves()
+	if err != nil {
+		log.Println("Error reading saves:", err)
+		return
+	}
+	if flightRecorder.IsEmpty() && len(bookmarks) == 0 {
+		delete(saves, saveKey(l))
+	} else {
+		saves[saveKey(l)] = SaveGame{
+			Moves:     flightRecorder.Bytes(),
+			Bookmarks: bookmarks,
+			Seconds:   challenge.elapsed().Seconds(),
+			When:      time.Now(),
+		}
+	}
+	if err := writeJSON(profilePath(savesFile), saves); err != nil {
+		log.Println("Error saving game:", err)
+	}
+}
+
+// resumeGame replays the saved moves of a level and brings back its bookmarks and clock, if it was left unfinished
+func resumeGame(l Level, level []string) {
+	saves, err := loadSaves()
+	if err != nil {
+		log.Println("Error reading saves:", err)
+		return
+	}
+	save, ok := saves[saveKey(l)]
+	if !ok {
+		return
+	}
+	flightRecorder.LoadBytes(save.Moves)
+	replayLog(level)
+	// the time already spent counts towards the best time, not just the time since resuming
+	challenge.started = time.Now().Add(-time.Duration(save.Seconds * float64(time.Second)))
+	for slot, mark := range save.Bookmarks {
+		if len(mark.Position.Players) == len(players) && len(mark.Position.Boulders) == len(boulders) {
+			bookmarks[slot] = mark
+		}
+	}
+}
+
+// dropGame forgets the save of a completed level
+func dropGame(l Level) {
+	saves, err := loadSaves()
+	if err != nil || len(saves) == 0 {
+		return
+	}
+	delete(saves, saveKey(l))
+	if err := writeJSON(profilePath(savesFile), saves); err != nil {
+		log.Println("Error saving game:", err)
+	}
+}
+
+// setBookmark marks the current position in a slot, saving the game so the mark outlives the session
+func setBookmark(l Level, slot int) {
+	bookmarks[slot] = Bookmark{Moves: flightRecorder.Bytes(), Position: takePosition()}
+	saveGame(l)
+}
+
+// jumpToBookmark goes straight back to a marked position, keeping the line being left as a branch
+func jumpToBookmark(level []string, slot int) {
+	mark, ok := bookmarks[slot]
+	if !ok {
+		return
+	}
+	saveBranch()
+	flightRecorder.LoadBytes(mark.Moves)
+	redoRecorder.Reset()
+	mark.Position.restore(level)
+}
+
+// bookmarkKey handles m followed by a slot to mark, and a slot on its own to jump back
+func bookmarkKey(l Level, level []string, evt string) bool {
+	slot := 0
+	if len(evt) == 1 && evt[0] >= '1' && evt[0] <= '9' {
+		slot = int(evt[0] - '0')
+	}
+	switch {
+	case marking:
+		marking = false
+		if slot > 0 {
+			setBookmark(l, slot)
+		}
+		return true
+	case evt == "m":
+		marking = true
+		return true
+	case slot > 0:
+		jumpToBookmark(level, slot)
+		return true
+	}
+	return false
+}
+
+func bookmarkStatus() string {
+	if marking {
+		return "mark which slot? 1-9"
+	}
+	if len(bookmarks) == 0 {
+		return ""
+	}
+	var slots []int
+	for slot := range bookmarks {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	var names []string
+	for _, slot := range slots {
+		names = append(names, fmt.Sprint(slot))
+	}
+	return "bookmarks " + strings.Join(names, " ")
+}
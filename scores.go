@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	scoresFile = "scores.json"
+	topEntries = 5
+)
+
+// ScoreEntry - one profile's best results on a level, with the solutions that set them
+type ScoreEntry struct {
+	Profile        string    `json:"profile"`
+	Moves          int       `json:"moves"`
+	Pushes         int       `json:"pushes"`
+	Seconds        float64   `json:"seconds"`
+	Solution       string    `json:"solution"`
+	PushesSolution string    `json:"pushes_solution"`
+	Solves         int       `json:"solves"`
+	When           time.Time `json:"when"`
+}
+
+// ScoreBoard - the best entries of every level, keyed by level hash so they survive packs being reordered
+type ScoreBoard map[string][]*ScoreEntry
+
+func loadScores() (ScoreBoard, error) {
+	board := ScoreBoard{}
+	err := readJSON(scoresFile, &board)
+	return board, err
+}
+
+// record keeps a solution if it beats the profile's best moves, pushes or time, reporting whether it did
+func (board ScoreBoard) record(key string, profile string, solution string, seconds float64) bool {
+	moves, pushes := len(solution), countPushes(solution)
+	var entry *ScoreEntry
+	for _, e := range board[key] {
+		if e.Profile == profile {
+			entry = e
+		}
+	}
+	if entry == nil {
+		board[key] = append(board[key], &ScoreEntry{
+			Profile: profile, Moves: moves, Pushes: pushes, Seconds: seconds,
+			Solution: solution, PushesSolution: solution, Solves: 1, When: time.Now(),
+		})
+		return true
+	}
+	entry.Solves++
+	improved := false
+	if moves < entry.Moves || moves == entry.Moves && pushes < countPushes(entry.Solution) {
+		entry.Moves, entry.Solution = moves, solution
+		improved = true
+	}
+	if pushes < entry.Pushes || pushes == entry.Pushes && moves < len(entry.PushesSolution) {
+		entry.Pushes, entry.PushesSolution = pushes, solution
+		improved = true
+	}
+	if seconds < entry.Seconds {
+		entry.Seconds = seconds
+		improved = true
+	}
+	if improved {
+		entry.When = time.Now()
+	}
+	return improved
+}
+
+// top - the entries of a level ordered by moves, then pushes, then time
+func (board ScoreBoard) top(key string) []*ScoreEntry {
+	entries := append([]*ScoreEntry(nil), board[key]...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Moves != b.Moves {
+			return a.Moves < b.Moves
+		}
+		if a.Pushes != b.Pushes {
+			return a.Pushes < b.Pushes
+		}
+		return a.Seconds < b.Seconds
+	})
+	return entries
+}
+
+// recordScore saves the solution of a completed level to the scores database
+func recordScore(l Level, level []string) (ScoreBoard, bool, error) {
+	board, err := loadScores()
+	if err != nil {
+		return nil, false, err
+	}
+	key := LevelHash(l, false)
+	improved := board.record(key, profile.Name, finalSolution(level), challenge.elapsed().Seconds())
+	if !solutionReplays() {
+		for _, e := range board[key] {
+			if e.Profile == profile.Name {
+				e.Solution, e.PushesSolution = "", ""
+			}
+		}
+	}
+	return board, improved, writeJSON(scoresFile, board)
+}
+
+func printScores(w *strings.Builder, entries []*ScoreEntry, limit int) {
+	fmt.Fprintf(w, "%-3s %-16s %6s %6s %8s %6s\n", "#", "profile", "moves", "pushes", "time", "solves")
+	for i, e := range entries {
+		if i == limit {
+			break
+		}
+		fmt.Fprintf(w, "%-3d %-16s %6d %6d %7.1fs %6d\n", i+1, e.Profile, e.Moves, e.Pushes, e.Seconds, e.Solves)
+	}
+}
+
+// scoredPlay reports whether a completed level belongs on the scores board, which only holds casual
+// play under the level's own rules; challenges keep their results apart and -extended changes the level
+func scoredPlay(l Level) bool {
+	return challenge.mode == casual && (!forceExtended || l.Meta.Header["Rules"] == "extended")
+}
+
+// showScores is the panel shown when a level is completed, waiting for a key before the next level
+func showScores(l Level, level []string, input <-chan string) {
+	board, improved, err := recordScore(l, level)
+	if err != nil {
+		log.Println("Error saving score:", err)
+		return
+	}
+	var b strings.Builder
+	if improved {
+		b.WriteString("New personal best!\n")
+	}
+	printScores(&b, board.top(LevelHash(l, false)), topEntries)
+	fmt.Print(b.String())
+	fmt.Println("press any key to continue")
+	<-input
+}
+
+func runScores(args []string) error {
+	fs := flag.NewFlagSet("scores", flag.ContinueOnError)
+	pack := fs.String("pack", "", "level pack to show the scores of (default the bundled pack)")
+	level := fs.Int("level", -1, "show the full table of one maze")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	levels, err := LoadPack(*pack)
+	if err != nil {
+		return err
+	}
+	board, err := loadScores()
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if *level >= 0 {
+		if *level >= len(levels) {
+			return fmt.Errorf("level %d out of range, the pack has %d levels", *level, len(levels))
+		}
+		entries := board.top(LevelHash(levels[*level], false))
+		if len(entries) == 0 {
+			return fmt.Errorf("no scores for maze %d", *level)
+		}
+		fmt.Fprintf(&b, "Maze %d\n", *level)
+		printScores(&b, entries, len(entries))
+		for i, e := range entries {
+			if e.Solution != "" {
+				fmt.Fprintf(&b, "%d %s: %s\n", i+1, e.Profile, e.Solution)
+			}
+		}
+		fmt.Print(b.String())
+		return nil
+	}
+	for i, l := range levels {
+		entries := board.top(LevelHash(l, false))
+		if len(entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "Maze %d\n", i)
+		printScores(&b, entries, topEntries)
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		return errors.New("no scores for this pack yet")
+	}
+	fmt.Print(b.String())
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// slcPack - a SokobanLevels XML collection, as read from .slc files
+type slcPack struct {
+	XMLName     xml.Name `xml:"SokobanLevels"`
+	Title       string   `xml:"Title"`
+	Description string   `xml:"Description,omitempty"`
+	Email       string   `xml:"Email,omitempty"`
+	URL         string   `xml:"Url,omitempty"`
+	Collection  struct {
+		Copyright string     `xml:"Copyright,attr,omitempty"`
+		MaxWidth  int        `xml:"MaxWidth,attr,omitempty"`
+		MaxHeight int        `xml:"MaxHeight,attr,omitempty"`
+		Levels    []slcLevel `xml:"Level"`
+	} `xml:"LevelCollection"`
+}
+
+type slcLevel struct {
+	ID        string   `xml:"Id,attr"`
+	Width     int      `xml:"Width,attr"`
+	Height    int      `xml:"Height,attr"`
+	Copyright string   `xml:"Copyright,attr,omitempty"`
+	Rows      []string `xml:"L"`
+}
+
+// isSLC tells an SLC collection by its XML root
+func isSLC(lines []string) bool {
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			return strings.HasPrefix(line, "<?xml") || strings.HasPrefix(line, "<SokobanLevels")
+		}
+	}
+	return false
+}
+
+// slcCharset reads the encodings other than UTF-8 that SLC files are found in: Latin-1, where every
+// byte is the code point of the same number, and plain ASCII, which is a part of it
+func slcCharset(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "us-ascii":
+		raw, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		text := make([]rune, len(raw))
+		for i, b := range raw {
+			text[i] = rune(b)
+		}
+		return strings.NewReader(string(text)), nil
+	}
+	return nil, fmt.Errorf("unsupported SLC encoding %q", charset)
+}
+
+// ParseSLC - reading an SLC collection, keeping the collection's title, description, email, url and
+// copyright in every level's header next to the level's own title and copyright
+func ParseSLC(lines []string) ([]Level, error) {
+	var pack slcPack
+	dec := xml.NewDecoder(strings.NewReader(strings.Join(lines, "\n")))
+	dec.CharsetReader = slcCharset
+	if err := dec.Decode(&pack); err != nil {
+		return nil, err
+	}
+	collection := map[string]string{
+		"Collection":           pack.Title,
+		"Description":          pack.Description,
+		"Email":                pack.Email,
+		"Url":                  pack.URL,
+		"Collection copyright": pack.Collection.Copyright,
+	}
+	var levels []Level
+	for i, l := range pack.Collection.Levels {
+		meta := newLevelMeta(i)
+		for key, value := range collection {
+			if value = strings.Join(strings.Fields(value), " "); value != "" {
+				meta.Header[key] = value
+			}
+		}
+		if l.ID != "" {
+			meta.Header["Title"] = l.ID
+		}
+		if l.Copyright != "" {
+			meta.Header["Copyright"] = l.Copyright
+		}
+		grid := make([]string, len(l.Rows))
+		for x, line := range l.Rows {
+			grid[x] = mapTiles(line, fromXSB)
+		}
+		levels = append(levels, Level{Meta: meta, Grid: padGrid(grid)})
+	}
+	return levels, nil
+}
+
+// WriteSLC - write levels as an SLC collection. The collection details come from the first level's
+// header; other header lines have no place in SLC and are left out.
+func WriteSLC(w io.Writer, levels []Level) error {
+	var pack slcPack
+	if len(levels) > 0 {
+		header := levels[0].Meta.Header
+		pack.Title = header["Collection"]
+		pack.Description = header["Description"]
+		pack.Email = header["Email"]
+		pack.URL = header["Url"]
+		pack.Collection.Copyright = header["Collection copyright"]
+	}
+	for i, l := range levels {
+		level := slcLevel{ID: l.Meta.Header["Title"], Copyright: l.Meta.Header["Copyright"]}
+		if level.ID == "" {
+			level.ID = fmt.Sprintf("Maze %d", i)
+		}
+		for _, line := range l.Grid {
+			level.Rows = append(level.Rows, strings.TrimRight(mapTiles(line, toXSB), " "))
+		}
+		level.Width, level.Height = gridSize(level.Rows)
+		if level.Width > pack.Collection.MaxWidth {
+			pack.Collection.MaxWidth = level.Width
+		}
+		if level.Height > pack.Collection.MaxHeight {
+			pack.Collection.MaxHeight = level.Height
+		}
+		pack.Collection.Levels = append(pack.Collection.Levels, level)
+	}
+	out, err := xml.MarshalIndent(pack, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, out)
+	return err
+}
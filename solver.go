@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"sort"
+)
+
+// puzzle - a level flattened to cell indices, padded with a wall border, for searching
+type puzzle struct {
+	width, height int
+	walls         []bool
+	goals         []bool
+	dead          []bool
+	player        int
+	boxes         []int
+}
+
+type direction struct {
+	move   Move
+	dx, dy int
+	key    byte
+}
+
+var directions = []direction{
+	{up, -1, 0, 'u'},
+	{down, 1, 0, 'd'},
+	{left, 0, -1, 'l'},
+	{right, 0, 1, 'r'},
+}
+
+const defaultSolverLimit = 200000
+
+var errNoSolution = errors.New("no solution found")
+
+func newPuzzle(level []string) (*puzzle, error) {
+	level = trimLevel(level)
+	w, h := gridSize(level)
+	p := &puzzle{width: w + 2, height: h + 2, player: -1}
+	n := p.width * p.height
+	p.walls = make([]bool, n)
+	p.goals = make([]bool, n)
+	for i := range p.walls {
+		p.walls[i] = true
+	}
+	goals := 0
+	for x, line := range level {
+		for y, char := range line {
+			c := p.index(x, y)
+			p.walls[c] = char == 'X'
+			switch char {
+			case '@', '+':
+				if p.player >= 0 {
+					return nil, errors.New("more than one player")
+				}
+				p.player = c
+				if char == '+' {
+					p.goals[c] = true
+					goals++
+				}
+			case '*':
+				p.boxes = append(p.boxes, c)
+			case '.':
+				p.goals[c] = true
+				goals++
+			case '&':
+				p.boxes = append(p.boxes, c)
+				p.goals[c] = true
+				goals++
+			}
+		}
+	}
+	if p.player < 0 {
+		return nil, errors.New("no player")
+	}
+	if goals != len(p.boxes) {
+		return nil, errors.New("box and goal counts differ")
+	}
+	sort.Ints(p.boxes)
+	p.markDead()
+	return p, nil
+}
+
+func (p *puzzle) index(x int, y int) int {
+	return (x+1)*p.width + y + 1
+}
+
+func (p *puzzle) offset(d direction) int {
+	return d.dx*p.width + d.dy
+}
+
+// markDead flags the floor cells from which a box can never reach a goal
+func (p *puzzle) markDead() {
+	live := make([]bool, len(p.walls))
+	var queue []int
+	for c, g := range p.goals {
+		if g {
+			live[c] = true
+			queue = append(queue, c)
+		}
+	}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, d := range directions {
+			from := c - p.offset(d)
+			behind := from - p.offset(d)
+			if from < 0 || behind < 0 || live[from] || p.walls[from] || p.walls[behind] {
+				continue
+			}
+			live[from] = true
+			queue = append(queue, from)
+		}
+	}
+	p.dead = make([]bool, len(p.walls))
+	for c := range p.dead {
+		p.dead[c] = !live[c] && !p.walls[c]
+	}
+}
+
+// reach returns the cells the player can walk to and the lowest of them, used to normalise states
+func (p *puzzle) reach(player int, occupied []bool) ([]bool, int) {
+	seen := make([]bool, len(p.walls))
+	seen[player] = true
+	low := player
+	queue := []int{player}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, d := range directions {
+			n := c + p.offset(d)
+			if seen[n] || p.walls[n] || occupied[n] {
+				continue
+			}
+			seen[n] = true
+			if n < low {
+				low = n
+			}
+			queue = append(queue, n)
+		}
+	}
+	return seen, low
+}
+
+// walk returns the lurd moves taking the player from one cell to another around the boxes
+func (p *puzzle) walk(from int, to int, occupied []bool) (string, bool) {
+	if from == to {
+		return "", true
+	}
+	prev := make([]int, len(p.walls))
+	moveKey := make([]byte, len(p.walls))
+	for i := range prev {
+		prev[i] = -1
+	}
+	prev[from] = from
+	queue := []int{from}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, d := range directions {
+			n := c + p.offset(d)
+			if prev[n] >= 0 || p.walls[n] || occupied[n] {
+				continue
+			}
+			prev[n] = c
+			moveKey[n] = d.key
+			if n == to {
+				var path []byte
+				for at := to; at != from; at = prev[at] {
+					path = append(path, moveKey[at])
+				}
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return string(path), true
+			}
+			queue = append(queue, n)
+		}
+	}
+	return "", false
+}
+
+func (p *puzzle) solved(boxes []int) bool {
+	for _, b := range boxes {
+		if !p.goals[b] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *puzzle) occupancy(boxes []int) []bool {
+	occupied := make([]bool, len(p.walls))
+	for _, b := range boxes {
+		occupied[b] = true
+	}
+	return occupied
+}
+
+func stateKey(player int, boxes []int) string {
+	key := make([]byte, 0, 4*(len(boxes)+1))
+	for _, v := range append([]int{player}, boxes...) {
+		key = append(key, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+	return string(key)
+}
+
+type searchNode struct {
+	parent *searchNode
+	player int
+	boxes  []int
+	box    int
+	dir    direction
+}
+
+// Solve - breadth-first search over pushes, returning a push-optimal solution in lurd notation
+func (p *puzzle) Solve(limit int) (string, error) {
+	start := &searchNode{player: p.player, boxes: p.boxes}
+	_, low := p.reach(p.player, p.occupancy(p.boxes))
+	seen := map[string]bool{stateKey(low, p.boxes): true}
+	queue := []*searchNode{start}
+	for len(queue) > 0 && len(seen) <= limit {
+		node := queue[0]
+		queue = queue[1:]
+		if p.solved(node.boxes) {
+			return p.lurd(node)
+		}
+		occupied := p.occupancy(node.boxes)
+		reach, _ := p.reach(node.player, occupied)
+		for i, b := range node.boxes {
+			for _, d := range directions {
+				behind := b - p.offset(d)
+				to := b + p.offset(d)
+				if !reach[behind] || p.walls[to] || occupied[to] || p.dead[to] {
+					continue
+				}
+				boxes := append([]int(nil), node.boxes...)
+				boxes[i] = to
+				sort.Ints(boxes)
+				occupied[b], occupied[to] = false, true
+				_, low := p.reach(b, occupied)
+				occupied[b], occupied[to] = true, false
+				key := stateKey(low, boxes)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				queue = append(queue, &searchNode{node, b, boxes, b, d})
+			}
+		}
+	}
+	return "", errNoSolution
+}
+
+func (p *puzzle) lurd(goal *searchNode) (string, error) {
+	var pushes []*searchNode
+	for n := goal; n.parent != nil; n = n.parent {
+		pushes = append(pushes, n)
+	}
+	var solution []byte
+	player := p.player
+	for i := len(pushes) - 1; i >= 0; i-- {
+		n := pushes[i]
+		path, ok := p.walk(player, n.box-p.offset(n.dir), p.occupancy(n.parent.boxes))
+		if !ok {
+			return "", errNoSolution
+		}
+		solution = append(solution, path...)
+		solution = append(solution, n.dir.key-'a'+'A')
+		player = n.box
+	}
+	return string(solution), nil
+}
+
+// SolveLevel - find a push-optimal solution for a level grid
+func SolveLevel(level []string) (string, error) {
+	p, err := newPuzzle(level)
+	if err != nil {
+		return "", err
+	}
+	return p.Solve(defaultSolverLimit)
+}
+
+// countPushes - the number of pushes in a lurd solution
+func countPushes(solution string) int {
+	n := 0
+	for _, c := range solution {
+		if c >= 'A' && c <= 'Z' {
+			n++
+		}
+	}
+	return n
+}
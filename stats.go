@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danicat/simpleansi"
+)
+
+// Stats - lifetime statistics of a profile, worked out from its sessions
+type Stats struct {
+	Sessions      int
+	Moves         int
+	Pushes        int
+	Undos         int
+	Solved        int
+	Played        time.Duration
+	HardestLevel  string
+	HardestTries  int
+	CurrentStreak int
+	LongestStreak int
+}
+
+func readSessions() ([]Session, error) {
+	var sessions []Session
+	err := readJSONLines(profilePath(sessionsFile), func(line []byte) error {
+		var s Session
+		if err := json.Unmarshal(line, &s); err != nil {
+			return err
+		}
+		sessions = append(sessions, s)
+		return nil
+	})
+	return sessions, err
+}
+
+// computeStats adds up the sessions; a streak is a run of days, up to today, on which a level was solved
+func computeStats(sessions []Session, today time.Time) Stats {
+	var st Stats
+	solved := map[string]bool{}
+	attempts := map[string]int{}
+	days := map[string]bool{}
+	labels := map[string]string{}
+	for _, s := range sessions {
+		key := s.Hash
+		st.Sessions++
+		st.Moves += s.Moves
+		st.Pushes += s.Pushes
+		st.Undos += s.Undos
+		st.Played += time.Duration(s.Seconds * float64(time.Second))
+		attempts[key]++
+		labels[key] = fmt.Sprintf("maze %d of %s", s.Level, s.Pack)
+		if s.Completed {
+			solved[key] = true
+			days[s.When.Local().Format("2006-01-02")] = true
+		}
+	}
+	st.Solved = len(solved)
+	for key, n := range attempts {
+		if n > st.HardestTries || n == st.HardestTries && labels[key] < st.HardestLevel {
+			st.HardestLevel, st.HardestTries = labels[key], n
+		}
+	}
+
+	var sorted []string
+	for day := range days {
+		sorted = append(sorted, day)
+	}
+	sort.Strings(sorted)
+	run := 0
+	var previous time.Time
+	for _, day := range sorted {
+		t, _ := time.ParseInLocation("2006-01-02", day, time.Local)
+		if run > 0 && t.Equal(previous.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > st.LongestStreak {
+			st.LongestStreak = run
+		}
+		previous = t
+	}
+	todayDay := today.Local().Format("2006-01-02")
+	yesterday := today.Local().AddDate(0, 0, -1).Format("2006-01-02")
+	if len(sorted) > 0 && (sorted[len(sorted)-1] == todayDay || sorted[len(sorted)-1] == yesterday) {
+		st.CurrentStreak = run
+	}
+	return st
+}
+
+// undoRatio - undos per move made
+func (st Stats) undoRatio() float64 {
+	if st.Moves == 0 {
+		return 0
+	}
+	return float64(st.Undos) / float64(st.Moves)
+}
+
+func (st Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Statistics of %s\n\n", profile.Name)
+	fmt.Fprintf(&b, "levels solved    %d\n", st.Solved)
+	fmt.Fprintf(&b, "attempts         %d\n", st.Sessions)
+	fmt.Fprintf(&b, "time played      %s\n", st.Played.Round(time.Second))
+	fmt.Fprintf(&b, "moves            %d\n", st.Moves)
+	fmt.Fprintf(&b, "pushes           %d\n", st.Pushes)
+	fmt.Fprintf(&b, "undos            %d\n", st.Undos)
+	fmt.Fprintf(&b, "undo ratio       %.2f per move\n", st.undoRatio())
+	if st.HardestTries > 0 {
+		fmt.Fprintf(&b, "hardest level    %s (%d attempts)\n", st.HardestLevel, st.HardestTries)
+	}
+	fmt.Fprintf(&b, "current streak   %d days\n", st.CurrentStreak)
+	fmt.Fprintf(&b, "longest streak   %d days\n", st.LongestStreak)
+	return b.String()
+}
+
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	profileName := fs.String("profile", defaultProfile, "player profile")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	names, err := profileNames()
+	if err != nil {
+		return err
+	}
+	known := false
+	for _, name := range names {
+		known = known || name == *profileName
+	}
+	if !known {
+		return fmt.Errorf("unknown profile %q", *profileName)
+	}
+	if err := readProfile(*profileName); err != nil {
+		return err
+	}
+	sessions, err := readSessions()
+	if err != nil {
+		return err
+	}
+	fmt.Print(computeStats(sessions, time.Now()))
+	return nil
+}
+
+// showStats is the statistics screen of the menu, closed by any key
+func showStats(input <-chan string) {
+	simpleansi.ClearScreen()
+	sessions, err := readSessions()
+	if err != nil {
+		fmt.Println("Error reading sessions:", err)
+	} else {
+		fmt.Print(computeStats(sessions, time.Now()))
+	}
+	fmt.Println("\npress any key to return")
+	<-input
+}
@@ -6,8 +6,21 @@ import (
 	"os/exec"
 )
 
+// keyboard - where keys are read from: stdin, or the controlling terminal when stdin is a piped level
+var keyboard = os.Stdin
+
+// useTerminalKeyboard reads keys from the controlling terminal instead of stdin
+func useTerminalKeyboard() error {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return err
+	}
+	keyboard = tty
+	return nil
+}
+
 func runTerminal(term *exec.Cmd) error {
-	term.Stdin = os.Stdin
+	term.Stdin = keyboard
 	return term.Run()
 }
 
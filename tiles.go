@@ -0,0 +1,191 @@
+package main
+
+import "github.com/danicat/simpleansi"
+
+// extendedRules turns on the special floor tiles below; without it they are plain floor
+var extendedRules bool
+
+var forceExtended bool
+
+// TileBehavior - how a special floor tile treats the pieces moving over it under the extended rules
+type TileBehavior interface {
+	// Enter reports whether a piece may move onto the tile in the given direction
+	Enter(level []string, dir Move) bool
+	// Arrive returns where a piece that has just moved onto the tile at x, y comes to rest
+	Arrive(level []string, x int, y int, dir Move, box bool) (int, int)
+	// Glyph is how the tile is drawn in the terminal
+	Glyph(level []string) string
+}
+
+// iceTile - boxes pushed onto ice keep sliding until something stops them
+type iceTile struct{}
+
+// arrowTile - one-way floor that can only be entered moving in its direction
+type arrowTile struct {
+	dir   Move
+	glyph string
+}
+
+// teleporterTile - the player stepping on it comes out of the teleporter with the same number
+type teleporterTile struct {
+	pair byte
+}
+
+// plateTile - a pressure plate; gates are open while a box stands on every plate
+type plateTile struct{}
+
+// gateTile - a wall while any pressure plate is empty
+type gateTile struct{}
+
+var tileBehaviors = map[byte]TileBehavior{
+	'~': iceTile{},
+	'^': arrowTile{up, "^"},
+	'v': arrowTile{down, "v"},
+	'<': arrowTile{left, "<"},
+	'>': arrowTile{right, ">"},
+	'_': plateTile{},
+	'|': gateTile{},
+}
+
+func init() {
+	for n := byte('1'); n <= '9'; n++ {
+		tileBehaviors[n] = teleporterTile{n}
+	}
+}
+
+func (iceTile) Enter(level []string, dir Move) bool {
+	return true
+}
+
+func (iceTile) Arrive(level []string, x int, y int, dir Move, box bool) (int, int) {
+	if !box {
+		return x, y
+	}
+	for tileAt(level, x, y) == '~' {
+		nx, ny := moves[dir](level, x, y)
+		if isPositionOccupied(level, nx, ny) || !canEnter(level, nx, ny, dir) {
+			break
+		}
+		x, y = nx, ny
+	}
+	return x, y
+}
+
+func (iceTile) Glyph(level []string) string {
+	return simpleansi.WithBackground(" ", simpleansi.CYAN)
+}
+
+func (a arrowTile) Enter(level []string, dir Move) bool {
+	return dir == a.dir
+}
+
+func (arrowTile) Arrive(level []string, x int, y int, dir Move, box bool) (int, int) {
+	return x, y
+}
+
+func (a arrowTile) Glyph(level []string) string {
+	return a.glyph
+}
+
+func (teleporterTile) Enter(level []string, dir Move) bool {
+	return true
+}
+
+func (t teleporterTile) Arrive(level []string, x int, y int, dir Move, box bool) (int, int) {
+	if box {
+		return x, y
+	}
+	for px, line := range level {
+		for py := range line {
+			if line[py] == t.pair && (px != x || py != y) && getBoulderAtPosition(px, py) == nil && !playerAtPosition(px, py) {
+				return px, py
+			}
+		}
+	}
+	return x, y
+}
+
+func (t teleporterTile) Glyph(level []string) string {
+	return string(t.pair)
+}
+
+func (plateTile) Enter(level []string, dir Move) bool {
+	return true
+}
+
+func (plateTile) Arrive(level []string, x int, y int, dir Move, box bool) (int, int) {
+	return x, y
+}
+
+func (plateTile) Glyph(level []string) string {
+	return "_"
+}
+
+func (gateTile) Enter(level []string, dir Move) bool {
+	return gatesOpen(level)
+}
+
+func (gateTile) Arrive(level []string, x int, y int, dir Move, box bool) (int, int) {
+	return x, y
+}
+
+func (gateTile) Glyph(level []string) string {
+	if gatesOpen(level) {
+		return ":"
+	}
+	return simpleansi.WithBackground(" ", simpleansi.BROWN)
+}
+
+// gatesOpen reports whether a box stands on every pressure plate
+func gatesOpen(level []string) bool {
+	for x, line := range level {
+		for y := range line {
+			if line[y] == '_' && getBoulderAtPosition(x, y) == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func behaviorAt(level []string, x int, y int) TileBehavior {
+	if !extendedRules {
+		return nil
+	}
+	return tileBehaviors[tileAt(level, x, y)]
+}
+
+func canEnter(level []string, x int, y int, dir Move) bool {
+	if b := behaviorAt(level, x, y); b != nil {
+		return b.Enter(level, dir)
+	}
+	return true
+}
+
+func arrive(level []string, x int, y int, dir Move, box bool) (int, int) {
+	if b := behaviorAt(level, x, y); b != nil {
+		return b.Arrive(level, x, y, dir, box)
+	}
+	return x, y
+}
+
+// teleportExits lists where the player can come out when standing on a teleporter
+func teleportExits(level []string, c cell) []cell {
+	t, ok := behaviorAt(level, c.X, c.Y).(teleporterTile)
+	if !ok {
+		return nil
+	}
+	var exits []cell
+	for x, line := range level {
+		for y := range line {
+			if line[y] == t.pair && (x != c.X || y != c.Y) {
+				exits = append(exits, cell{x, y})
+			}
+		}
+	}
+	return exits
+}
+
+func isExtendedLevel(l Level) bool {
+	return forceExtended || l.Meta.Header["Rules"] == "extended"
+}
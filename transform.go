@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Transform - one of the eight symmetries of a square level: Turns quarter turns clockwise,
+// followed by a left to right mirror when Mirror is set
+type Transform struct {
+	Turns  int
+	Mirror bool
+}
+
+var transforms = map[string]Transform{
+	"none":          {0, false},
+	"rot90":         {1, false},
+	"rot180":        {2, false},
+	"rot270":        {3, false},
+	"mirror":        {0, true},
+	"transpose":     {1, true},
+	"flip":          {2, true},
+	"antitranspose": {3, true},
+}
+
+// turned - where each move points after a quarter turn clockwise
+var turned = map[Move]Move{up: right, right: down, down: left, left: up}
+
+var mirrored = map[Move]Move{left: right, right: left, up: up, down: down}
+
+// arrowMoves - the direction of each one-way floor tile, which turns with the level
+var arrowMoves = map[byte]Move{'^': up, 'v': down, '<': left, '>': right}
+
+// Move - the direction a move points in the transformed level
+func (t Transform) Move(m Move) Move {
+	for i := 0; i < t.Turns; i++ {
+		m = turned[m]
+	}
+	if t.Mirror {
+		m = mirrored[m]
+	}
+	return m
+}
+
+// LURD - a lurd solution of a level turned into the solution of the transformed level
+func (t Transform) LURD(solution string) (string, error) {
+	out := make([]byte, 0, len(solution))
+	for i, c := range solution {
+		dir, ok := lurdMoves[c|0x20]
+		if !ok || dir > right {
+			return "", fmt.Errorf("move %d: %q is not a lurd move of a square level", i+1, c)
+		}
+		out = append(out, stepKey(t.Move(dir), true, c >= 'A' && c <= 'Z'))
+	}
+	return string(out), nil
+}
+
+// Grid - a grid turned and mirrored, with the one-way floor pointing the new way
+func (t Transform) Grid(grid []string) []string {
+	grid = normalizeGrid(grid)
+	for i := 0; i < t.Turns; i++ {
+		grid = rotateGrid(grid)
+	}
+	if t.Mirror {
+		grid = mirrorGrid(grid)
+	}
+	arrows := map[Move]byte{}
+	for tile, dir := range arrowMoves {
+		arrows[dir] = tile
+	}
+	for x, line := range grid {
+		row := []byte(line)
+		for y, tile := range row {
+			if dir, ok := arrowMoves[tile]; ok {
+				row[y] = arrows[t.Move(dir)]
+			}
+		}
+		grid[x] = string(row)
+	}
+	return grid
+}
+
+// Level - a level turned and mirrored, its Solution header and size following it
+func (t Transform) Level(l Level) (Level, error) {
+	if isHexLevel(l) {
+		return Level{}, errors.New("hex levels can't be turned or mirrored")
+	}
+	out := Level{Meta: l.Meta, Grid: t.Grid(l.Grid)}
+	out.Meta.Header = map[string]string{}
+	for key, value := range l.Meta.Header {
+		out.Meta.Header[key] = value
+	}
+	if solution := l.Meta.Header["Solution"]; solution != "" {
+		var err error
+		if out.Meta.Header["Solution"], err = t.LURD(solution); err != nil {
+			return Level{}, err
+		}
+	}
+	resizeHeader(&out)
+	return out, nil
+}
+
+// resizeHeader updates the size of a level, and its size headers when it has them, to its grid
+func resizeHeader(l *Level) {
+	l.Meta.Width, l.Meta.Height = gridSize(l.Grid)
+	if _, ok := l.Meta.Header["Size X"]; ok {
+		l.Meta.Header["Size X"] = strconv.Itoa(l.Meta.Width)
+		l.Meta.Header["Size Y"] = strconv.Itoa(l.Meta.Height)
+	}
+}
+
+// NormalizeLevel - a level with the floor no pusher can reach counted as outside, the walls that only
+// touch the outside taken away and the blank rows and columns around it trimmed. Moves stay the same.
+func NormalizeLevel(l Level) (Level, error) {
+	if isHexLevel(l) {
+		return Level{}, errors.New("hex levels can't be normalized")
+	}
+	grid := normalizeGrid(l.Grid)
+	inside := map[cell]bool{}
+	for x, line := range grid {
+		for y, tile := range line {
+			if tile == '@' || tile == '+' {
+				reach, _ := floodFill(grid, cell{x, y})
+				for c := range reach {
+					inside[c] = true
+				}
+			}
+		}
+	}
+	if len(inside) == 0 {
+		return Level{}, errors.New("levels without a player can't be normalized")
+	}
+	outside := map[cell]bool{}
+	for x, line := range grid {
+		for y, tile := range line {
+			if tile == ' ' && !inside[cell{x, y}] {
+				outside[cell{x, y}] = true
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for x, line := range grid {
+			for y := range line {
+				if line[y] == 'X' && onlyTouchesOutside(grid, outside, x, y) {
+					grid[x] = setTile(grid[x], y, ' ')
+					outside[cell{x, y}] = true
+					changed = true
+				}
+			}
+		}
+	}
+	out := Level{Meta: l.Meta, Grid: normalizeGrid(grid)}
+	out.Meta.Header = map[string]string{}
+	for key, value := range l.Meta.Header {
+		out.Meta.Header[key] = value
+	}
+	resizeHeader(&out)
+	return out, nil
+}
+
+// onlyTouchesOutside reports whether every cell around a wall, diagonals included, is outside or
+// another wall, with at least one outside
+func onlyTouchesOutside(grid []string, outside map[cell]bool, x int, y int) bool {
+	touches := false
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			n := cell{x + dx, y + dy}
+			switch {
+			case n.X < 0 || n.X >= len(grid) || n.Y < 0 || n.Y >= len(grid[n.X]) || outside[n]:
+				touches = true
+			case grid[n.X][n.Y] != 'X':
+				return false
+			}
+		}
+	}
+	return touches
+}
+
+// transformNames lists the symmetries, the turns first
+func transformNames() []string {
+	return []string{"none", "rot90", "rot180", "rot270", "mirror", "transpose", "flip", "antitranspose"}
+}
+
+func runTransform(args []string) error {
+	fs := flag.NewFlagSet("transform", flag.ContinueOnError)
+	pack := fs.String("pack", "", "level pack (default the bundled pack)")
+	level := fs.Int("level", -1, "maze to transform (default every maze)")
+	name := fs.String("t", "none", "symmetry to apply: "+strings.Join(transformNames(), ", ")+", or all for every one")
+	normalize := fs.Bool("normalize", false, "drop the floor no pusher can reach and the walls only it touches, and trim the level, first")
+	to := fs.String("to", "native", "format to write: native, xsb, slc or json")
+	out := fs.String("o", "", "output file (default standard output)")
+	solutions := fs.String("solutions", "", "directory of <level hash>.lurd solutions to transform along with the levels")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("usage: sokobango transform [-pack file] [-level N] [-t symmetry|all] [-normalize] [-to format] [-o file] [-solutions dir]")
+	}
+	names := []string{*name}
+	if *name == "all" {
+		names = transformNames()
+	} else if _, ok := transforms[*name]; !ok {
+		return fmt.Errorf("unknown symmetry %q", *name)
+	}
+	write, ok := packWriters[*to]
+	if !ok {
+		return fmt.Errorf("unknown format %q", *to)
+	}
+	levels, err := LoadPack(*pack)
+	if err != nil {
+		return err
+	}
+	if *level >= len(levels) {
+		return fmt.Errorf("level %d out of range, the pack has %d levels", *level, len(levels))
+	}
+	if *level >= 0 {
+		levels = levels[*level : *level+1]
+	}
+	var result []Level
+	for _, l := range levels {
+		from := l
+		if *normalize {
+			if l, err = NormalizeLevel(l); err != nil {
+				return fmt.Errorf("maze %d: %v", from.Meta.Number, err)
+			}
+		}
+		for _, n := range names {
+			t, err := transforms[n].Level(l)
+			if err != nil {
+				return fmt.Errorf("maze %d: %v", from.Meta.Number, err)
+			}
+			if len(names) > 1 {
+				t.Meta.Header["Symmetry"] = n
+			}
+			if *solutions != "" {
+				if err := transformSolution(*solutions, from, t, transforms[n]); err != nil {
+					return fmt.Errorf("maze %d: %v", from.Meta.Number, err)
+				}
+			}
+			result = append(result, t)
+		}
+	}
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	return write(w, result)
+}
+
+// transformSolution saves the stored solution of a level, if there is one, as the solution of its
+// transformed copy
+func transformSolution(dir string, from Level, to Level, t Transform) error {
+	saved, err := os.ReadFile(filepath.Join(dir, LevelHash(from, false)+".lurd"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	solution, err := t.LURD(strings.TrimSpace(string(saved)))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, LevelHash(to, false)+".lurd"), []byte(solution+"\n"), 0o644)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danicat/simpleansi"
+)
+
+// branches are the lines of play of the level kept when the player undoes moves and then plays
+// differently. Each is stored whole, from the start of the level, so together they form the undo
+// tree: two lines fork at the first move where they differ.
+var branches []*MoveLog
+
+// currentLine - the moves made so far followed by the undone moves redo would play again
+func currentLine() []Step {
+	line := flightRecorder.Steps()
+	future := redoRecorder.Steps()
+	for i := len(future) - 1; i >= 0; i-- {
+		line = append(line, future[i])
+	}
+	return line
+}
+
+// sharedMoves - how many moves two lines have in common before they fork
+func sharedMoves(a []Step, b []Step) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// saveBranch keeps the current line unless a kept line already continues it,
+// dropping kept lines it continues itself
+func saveBranch() {
+	line := currentLine()
+	if len(line) == 0 {
+		return
+	}
+	kept := branches[:0]
+	for _, b := range branches {
+		steps := b.Steps()
+		if sharedMoves(steps, line) == len(line) {
+			return
+		}
+		if sharedMoves(steps, line) < len(steps) {
+			kept = append(kept, b)
+		}
+	}
+	saved := &MoveLog{}
+	saved.Load(line)
+	branches = append(kept, saved)
+}
+
+// branchMove plays a move key, following the undone line when the move is the one redo would play
+// and otherwise keeping that line as a branch before starting a new one
+func branchMove(level []string, dir Move) {
+	if !redoRecorder.IsEmpty() {
+		if next, ok := redoRecorder.Last(); ok && next.Who == current && next.Dir == dir {
+			redo(level)
+			return
+		}
+		saveBranch()
+	}
+	redoRecorder.Reset()
+	movePlayer(level, dir)
+}
+
+// switchBranch moves to the end of a kept line, keeping the current one as a branch
+func switchBranch(level []string, i int) {
+	if i < 0 || i >= len(branches) {
+		return
+	}
+	target := branches[i].Steps()
+	saveBranch()
+	flightRecorder.Load(target)
+	redoRecorder.Reset()
+	replayLog(level)
+}
+
+// showBranches lists the branches with their move and push counts and where they fork from the
+// current line, switching to the one whose number is pressed
+func showBranches(level []string, input <-chan string) {
+	saveBranch()
+	line := currentLine()
+	played := len(flightRecorder.Steps())
+	var b strings.Builder
+	b.WriteString("Branches\n\n")
+	fmt.Fprintf(&b, "%-3s %6s %6s %10s\n", "#", "moves", "pushes", "forks at")
+	for i, branch := range branches {
+		if i == 9 {
+			fmt.Fprintf(&b, "... and %d more\n", len(branches)-i)
+			break
+		}
+		steps := branch.Steps()
+		pushes := 0
+		for _, s := range steps {
+			if s.Pushed {
+				pushes++
+			}
+		}
+		fork := fmt.Sprint(sharedMoves(steps, line))
+		if len(steps) == len(line) && sharedMoves(steps, line) == len(line) {
+			fork = fmt.Sprintf("here (%d)", played)
+		}
+		fmt.Fprintf(&b, "%-3d %6d %6d %10s\n", i+1, len(steps), pushes, fork)
+	}
+	b.WriteString("\npress a number to go to the end of that branch, any other key to return\n")
+	simpleansi.ClearScreen()
+	fmt.Print(b.String())
+	evt := <-input
+	if len(evt) == 1 && evt[0] >= '1' && evt[0] <= '9' {
+		switchBranch(level, int(evt[0]-'1'))
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var fromXSB = map[rune]byte{
+	'#': 'X',
+	'$': '*',
+	'*': '&',
+	'.': '.',
+	'@': '@',
+	'+': '+',
+	' ': ' ',
+	'-': ' ',
+	'_': ' ',
+}
+
+var toXSB = map[rune]byte{
+	'X': '#',
+	'*': '$',
+	'&': '*',
+	'.': '.',
+	'@': '@',
+	'+': '+',
+	' ': ' ',
+}
+
+func isXSBRow(line string) bool {
+	line = strings.TrimRight(line, " ")
+	if !strings.ContainsRune(line, '#') {
+		return false
+	}
+	for _, char := range line {
+		if _, ok := fromXSB[char]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseXSB - splitting a pack in the common XSB text format into levels
+func ParseXSB(lines []string) []Level {
+	var levels []Level
+	header := map[string]string{}
+	var grid []string
+	flush := func() {
+		if len(grid) == 0 {
+			return
+		}
+		meta := newLevelMeta(len(levels))
+		for k, v := range header {
+			meta.Header[k] = v
+		}
+		levels = append(levels, Level{Meta: meta, Grid: padGrid(grid)})
+		grid = nil
+		header = map[string]string{}
+	}
+	for _, line := range lines {
+		if isXSBRow(line) {
+			grid = append(grid, mapTiles(line, fromXSB))
+			continue
+		}
+		flush()
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, ";"):
+			if _, ok := header["Title"]; !ok {
+				header["Title"] = strings.TrimSpace(strings.TrimPrefix(line, ";"))
+			}
+		case strings.Contains(line, ":"):
+			kv := strings.SplitN(line, ":", 2)
+			header[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		default:
+			if _, ok := header["Title"]; !ok {
+				header["Title"] = line
+			}
+		}
+	}
+	flush()
+	return levels
+}
+
+// WriteXSB - write levels in the common XSB text format, the header lines following the title
+func WriteXSB(w io.Writer, levels []Level) error {
+	for i, l := range levels {
+		title := l.Meta.Header["Title"]
+		if title == "" {
+			title = "Maze " + strconv.Itoa(i)
+		}
+		if _, err := fmt.Fprintf(w, "; %s\n", title); err != nil {
+			return err
+		}
+		for _, key := range headerKeys(l.Meta.Header) {
+			if key == "Title" {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s: %s\n", key, l.Meta.Header[key]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+		for _, line := range l.Grid {
+			if _, err := fmt.Fprintln(w, strings.TrimRight(mapTiles(line, toXSB), " ")); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapTiles translates a row through a tile table, keeping the characters the table has no entry for
+func mapTiles(line string, table map[rune]byte) string {
+	row := make([]byte, 0, len(line))
+	for _, char := range line {
+		if tile, ok := table[char]; ok {
+			row = append(row, tile)
+		} else {
+			row = append(row, byte(char))
+		}
+	}
+	return string(row)
+}
+
+// unknownTile - the first character of a row that a tile table has no entry for, 0 when there is none
+func unknownTile(line string, table map[rune]byte) rune {
+	for _, char := range line {
+		if _, ok := table[char]; !ok {
+			return char
+		}
+	}
+	return 0
+}
+
+// padGrid right-pads every row with floor to the widest row
+func padGrid(grid []string) []string {
+	width, _ := gridSize(grid)
+	padded := make([]string, len(grid))
+	for i, line := range grid {
+		padded[i] = line + strings.Repeat(" ", width-len(line))
+	}
+	return padded
+}